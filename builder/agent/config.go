@@ -1,42 +1,178 @@
 package main
 
 import (
+	"log"
+	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/romain325/doc-thor/builder/agent/stages"
+	"github.com/romain325/doc-thor/builder/agent/storage"
 )
 
 // Config is populated entirely from environment variables.
 type Config struct {
-	ServerURL        string
-	ServerToken      string
-	StorageBucket    string
-	StorageEndpoint  string
-	StorageRegion    string
-	StorageAccessKey string
-	StorageSecretKey string
-	PollInterval     time.Duration
-	ContainerTimeout time.Duration
+	ServerURL string
+	// RegistrationToken is exchanged for ServerToken, the credential job
+	// polls and reports are made with, when the builder registers under Name
+	// at startup, and again whenever the server stops accepting it.
+	RegistrationToken string
+	ServerToken       *credential
+	Name              string
+	// Storage selects where build output goes: an S3-compatible bucket or a
+	// local directory served by nginx from disk.
+	Storage      storage.Config
+	PollInterval time.Duration
+	// Labels are declared when registering; the server only hands out builds
+	// of projects whose required labels are all among them.
+	Labels []string
+	// ContainerTimeout applies when a project requests none;
+	// ContainerMaxTimeout caps what a project may request (0 = no cap).
+	ContainerTimeout    time.Duration
+	ContainerMaxTimeout time.Duration
 	// WorkspaceDir is the base directory for per-job temp dirs (repo clone +
 	// build output).  It must be bind-mounted from the host at the exact same
 	// path so that the paths the builder passes to the Docker API are valid on
 	// the host daemon.
 	WorkspaceDir string
+	// PullPolicy decides when builder images are pulled from their registry;
+	// RegistryAuthFile is an optional Docker config.json with credentials.
+	PullPolicy       stages.PullPolicy
+	RegistryAuthFile string
+	// ArchiveFormat is the packaging of the downloadable copy of each
+	// version's output; "none" disables archives.
+	ArchiveFormat stages.ArchiveFormat
+	// ValidateOutput runs the validate stage between collect and upload;
+	// Validate holds its limits and link-check mode.
+	ValidateOutput bool
+	Validate       stages.ValidateConfig
+
+	// Build container sandboxing.  The Build* values apply when a project
+	// requests nothing; the BuildMax* values cap what a project may request.
+	// A zero value means "no limit".
+	BuildMemoryMB    int64
+	BuildMaxMemoryMB int64
+	BuildCPUs        float64
+	BuildMaxCPUs     float64
+	BuildPids        int64
+	BuildMaxPids     int64
+	// BuildNetwork is the default network access of build containers;
+	// BuildAllowNetwork=false forces it off regardless of project settings.
+	BuildNetwork      bool
+	BuildAllowNetwork bool
+	// BuildReadOnlyRootfs mounts the image filesystem read-only, with a tmpfs
+	// of BuildTmpfsMB at /tmp for scratch space.
+	BuildReadOnlyRootfs bool
+	BuildTmpfsMB        int64
+	// BuildUser is the uid[:gid] build containers run as.  Empty keeps the
+	// image's own USER, which is usually root.
+	BuildUser    string
+	BuildCapDrop []string
 }
 
 func loadConfig() Config {
 	pollSec, _ := strconv.Atoi(getEnv("POLL_INTERVAL", "5"))
 	timeoutSec, _ := strconv.Atoi(getEnv("CONTAINER_TIMEOUT", "300"))
+	maxTimeoutSec, _ := strconv.Atoi(getEnv("CONTAINER_MAX_TIMEOUT", "3600"))
+	if maxTimeoutSec > 0 && maxTimeoutSec < timeoutSec {
+		// The default must itself be allowed.
+		maxTimeoutSec = timeoutSec
+	}
+	memoryMB, _ := strconv.ParseInt(getEnv("BUILD_MEMORY_MB", "1024"), 10, 64)
+	maxMemoryMB, _ := strconv.ParseInt(getEnv("BUILD_MAX_MEMORY_MB", "4096"), 10, 64)
+	cpus, _ := strconv.ParseFloat(getEnv("BUILD_CPUS", "1"), 64)
+	maxCPUs, _ := strconv.ParseFloat(getEnv("BUILD_MAX_CPUS", "2"), 64)
+	pids, _ := strconv.ParseInt(getEnv("BUILD_PIDS", "256"), 10, 64)
+	maxPids, _ := strconv.ParseInt(getEnv("BUILD_MAX_PIDS", "1024"), 10, 64)
+	network, _ := strconv.ParseBool(getEnv("BUILD_NETWORK", "true"))
+	allowNetwork, _ := strconv.ParseBool(getEnv("BUILD_ALLOW_NETWORK", "true"))
+	readOnly, _ := strconv.ParseBool(getEnv("BUILD_READONLY_ROOTFS", "true"))
+	tmpfsMB, _ := strconv.ParseInt(getEnv("BUILD_TMPFS_MB", "256"), 10, 64)
+
+	pullPolicy, err := stages.ParsePullPolicy(getEnv("PULL_POLICY", string(stages.PullIfNotPresent)))
+	if err != nil {
+		log.Fatalf("PULL_POLICY: %v", err)
+	}
+	archiveFormat, err := stages.ParseArchiveFormat(getEnv("ARCHIVE_FORMAT", string(stages.ArchiveTarGz)))
+	if err != nil {
+		log.Fatalf("ARCHIVE_FORMAT: %v", err)
+	}
+	validate, _ := strconv.ParseBool(getEnv("VALIDATE_OUTPUT", "true"))
+	maxOutputMB, _ := strconv.ParseInt(getEnv("VALIDATE_MAX_SIZE_MB", "1024"), 10, 64)
+	maxFiles, _ := strconv.Atoi(getEnv("VALIDATE_MAX_FILES", "50000"))
+	linkCheck, err := stages.ParseLinkCheck(getEnv("VALIDATE_LINKS", string(stages.LinksWarn)))
+	if err != nil {
+		log.Fatalf("VALIDATE_LINKS: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
 
 	return Config{
 		ServerURL:        getEnv("SERVER_URL", "http://localhost:8080"),
-		ServerToken:      mustEnv("BUILDER_TOKEN"),
-		StorageBucket:    getEnv("STORAGE_BUCKET", "doc-thor-docs"),
-		StorageEndpoint:  mustEnv("STORAGE_ENDPOINT"),
-		StorageRegion:    getEnv("STORAGE_REGION", "us-east-1"),
-		StorageAccessKey: mustEnv("STORAGE_ACCESS_KEY"),
-		StorageSecretKey: mustEnv("STORAGE_SECRET_KEY"),
+		Storage:          loadStorageConfig(),
 		PollInterval:     time.Duration(pollSec) * time.Second,
+		Labels:           splitList(getEnv("BUILDER_LABELS", "")),
 		ContainerTimeout: time.Duration(timeoutSec) * time.Second,
 		WorkspaceDir:     mustEnv("WORKSPACE_DIR"),
+		PullPolicy:       pullPolicy,
+		RegistryAuthFile: getEnv("REGISTRY_AUTH_FILE", ""),
+		ArchiveFormat:    archiveFormat,
+		ValidateOutput:   validate,
+		Validate: stages.ValidateConfig{
+			MaxBytes: maxOutputMB * 1024 * 1024,
+			MaxFiles: maxFiles,
+			Links:    linkCheck,
+		},
+
+		RegistrationToken: mustEnv("BUILDER_REGISTRATION_TOKEN"),
+		Name:              getEnv("BUILDER_NAME", hostname),
+
+		ContainerMaxTimeout: time.Duration(maxTimeoutSec) * time.Second,
+
+		BuildMemoryMB:       memoryMB,
+		BuildMaxMemoryMB:    maxMemoryMB,
+		BuildCPUs:           cpus,
+		BuildMaxCPUs:        maxCPUs,
+		BuildPids:           pids,
+		BuildMaxPids:        maxPids,
+		BuildNetwork:        network,
+		BuildAllowNetwork:   allowNetwork,
+		BuildReadOnlyRootfs: readOnly,
+		BuildTmpfsMB:        tmpfsMB,
+		BuildUser:           getEnv("BUILD_USER", "65534:65534"),
+		BuildCapDrop:        splitList(getEnv("BUILD_CAP_DROP", "ALL")),
+	}
+}
+
+// loadStorageConfig reads the settings of the selected backend only, so a
+// local-storage builder needs no S3 credentials and vice versa.
+func loadStorageConfig() storage.Config {
+	cfg := storage.Config{Backend: getEnv("STORAGE_BACKEND", storage.BackendS3)}
+	switch cfg.Backend {
+	case storage.BackendS3:
+		cfg.S3 = storage.S3Config{
+			Endpoint:  mustEnv("STORAGE_ENDPOINT"),
+			Region:    getEnv("STORAGE_REGION", "us-east-1"),
+			AccessKey: mustEnv("STORAGE_ACCESS_KEY"),
+			SecretKey: mustEnv("STORAGE_SECRET_KEY"),
+			Bucket:    getEnv("STORAGE_BUCKET", "doc-thor-docs"),
+		}
+	case storage.BackendLocal:
+		cfg.LocalDir = mustEnv("STORAGE_LOCAL_DIR")
+	default:
+		log.Fatalf("STORAGE_BACKEND: unknown backend %q (want %s or %s)", cfg.Backend, storage.BackendS3, storage.BackendLocal)
+	}
+	return cfg
+}
+
+// splitList parses a comma-separated env value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/romain325/doc-thor/builder/agent/storage"
 )
 
 // Job is the payload the server sends when a build is pending.
@@ -17,7 +20,22 @@ type Job struct {
 	Version     string `json:"version"`
 	SourceURL   string `json:"source_url"`
 	Ref         string `json:"ref"`
+	Commit      string `json:"commit,omitempty"`
 	DockerImage string `json:"docker_image"`
+	// Resources is the project's requested sandbox; see sandboxFor.
+	Resources *ResourceRequest `json:"resources,omitempty"`
+	// Timeout is the project's requested container timeout in seconds; see
+	// timeoutFor.
+	Timeout int `json:"timeout,omitempty"`
+	// BaseURL is where this version will be served once published.
+	BaseURL string `json:"base_url"`
+	// Versions lists the project's other published versions; Latest is the
+	// one served at the bare subdomain, if any.
+	Versions []string `json:"versions"`
+	Latest   string   `json:"latest,omitempty"`
+	// Env holds the project's build variables, secrets already decrypted.
+	// They are passed to the build container as-is.
+	Env map[string]string `json:"env,omitempty"`
 }
 
 func getEnv(key, fallback string) string {
@@ -40,7 +58,7 @@ func pollForJob(ctx context.Context, cfg Config) (*Job, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.ServerToken)
+	req.Header.Set("Authorization", "Bearer "+cfg.ServerToken.get())
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -51,6 +69,9 @@ func pollForJob(ctx context.Context, cfg Config) (*Job, error) {
 	if resp.StatusCode == http.StatusNoContent {
 		return nil, nil
 	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errUnauthorized
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("poll: status %d", resp.StatusCode)
 	}
@@ -64,26 +85,45 @@ func pollForJob(ctx context.Context, cfg Config) (*Job, error) {
 
 func main() {
 	cfg := loadConfig()
-	log.Printf("builder started, polling %s every %s", cfg.ServerURL, cfg.PollInterval)
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+	cfg.ServerToken = &credential{}
+	cfg.ServerToken.set(mustRegister(cfg))
+	log.Printf("builder started, polling %s every %s with labels %v", cfg.ServerURL, cfg.PollInterval, cfg.Labels)
 
 	ticker := time.NewTicker(cfg.PollInterval)
 	defer ticker.Stop()
 
+	// wait grows while a fresh credential keeps being refused, so a server
+	// that rejects it does not get a registration every poll.
+	var wait time.Duration
 	for {
 		<-ticker.C
 
 		job, err := pollForJob(context.Background(), cfg)
+		if errors.Is(err, errUnauthorized) {
+			// The builder was deleted or registered elsewhere under its
+			// name: get a new credential.
+			log.Printf("credential refused; registering again in %s", wait)
+			time.Sleep(wait)
+			cfg.ServerToken.set(mustRegister(cfg))
+			wait = nextBackoff(wait, cfg.PollInterval)
+			continue
+		}
 		if err != nil {
 			log.Printf("poll error: %v", err)
 			continue
 		}
+		wait = 0
 		if job == nil {
 			continue
 		}
 
 		log.Printf("picked up job %s for project %s", job.ID, job.ProjectSlug)
 		go func(j Job) {
-			if err := runPipeline(cfg, j); err != nil {
+			if err := runPipeline(cfg, store, j); err != nil {
 				log.Printf("pipeline error for job %s: %v", j.ID, err)
 			}
 		}(*job)
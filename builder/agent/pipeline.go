@@ -4,40 +4,64 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/romain325/doc-thor/builder/agent/stages"
+	"github.com/romain325/doc-thor/builder/agent/storage"
 )
 
-func runPipeline(cfg Config, job Job) error {
+func runPipeline(cfg Config, store storage.Backend, job Job) error {
 	start := time.Now()
 
+	// containerLogs holds stdout+stderr captured during the run stage.
+	// It is populated before any later stage executes so that even a failure
+	// in collect/upload still includes the build output in the report.
+	var containerLogs string
+	// warnings collects non-fatal findings (see the validate stage); they are
+	// reported whatever the outcome.
+	var warnings []string
+	// output records where the upload stage put the files.
+	output := buildOutput{Storage: store.Name()}
+	// stageResults grows as the pipeline runs.
+	var stageResults []stageResult
+
+	// report fills in what every result carries and sends it.
+	report := func(res buildResult) {
+		res.JobID = job.ID
+		res.Duration = time.Since(start).String()
+		res.Logs = containerLogs
+		res.Warnings = warnings
+		res.Stages = stageResults
+		reportResult(cfg, res)
+	}
+
 	repoDir, err := os.MkdirTemp(cfg.WorkspaceDir, "builder-repo-"+job.ID)
 	if err != nil {
-		reportResult(cfg, job.ID, "failed", time.Since(start), fmt.Sprintf("create repo dir: %v", err), "")
+		report(buildResult{Status: "failed", Error: fmt.Sprintf("create repo dir: %v", err)})
 		return err
 	}
 	defer os.RemoveAll(repoDir)
 
 	outputDir, err := os.MkdirTemp(cfg.WorkspaceDir, "builder-output-"+job.ID)
 	if err != nil {
-		reportResult(cfg, job.ID, "failed", time.Since(start), fmt.Sprintf("create output dir: %v", err), "")
+		report(buildResult{Status: "failed", Error: fmt.Sprintf("create output dir: %v", err)})
 		return err
 	}
 	defer os.RemoveAll(outputDir)
 
-	s3Cfg := stages.S3Config{
-		Endpoint:  cfg.StorageEndpoint,
-		Region:    cfg.StorageRegion,
-		AccessKey: cfg.StorageAccessKey,
-		SecretKey: cfg.StorageSecretKey,
-		Bucket:    cfg.StorageBucket,
+	// MkdirTemp creates 0700 dirs owned by the builder; a build container
+	// running as an unprivileged user must still read /repo and write /output.
+	err = os.Chmod(repoDir, 0o755)
+	if err == nil {
+		err = os.Chmod(outputDir, 0o777)
+	}
+	if err != nil {
+		report(buildResult{Status: "failed", Error: fmt.Sprintf("prepare workspace: %v", err)})
+		return err
 	}
-
-	// containerLogs holds stdout+stderr captured during the run stage.
-	// It is populated before any later stage executes so that even a failure
-	// in collect/upload still includes the build output in the report.
-	var containerLogs string
 
 	type stage struct {
 		name string
@@ -51,28 +75,122 @@ func runPipeline(cfg Config, job Job) error {
 				return err
 			}
 			job.Ref = resolved
+			// The checkout is the source of truth for what is being built;
+			// a webhook-provided commit may already be stale.
+			commit, err := stages.HeadCommit(repoDir)
+			if err != nil {
+				return err
+			}
+			job.Commit = commit
 			return nil
 		}},
+		{"image", func() error {
+			return stages.EnsureImage(job.DockerImage, cfg.PullPolicy, cfg.RegistryAuthFile)
+		}},
 		{"run", func() error {
 			var err error
-			containerLogs, err = stages.Run(job.DockerImage, repoDir, outputDir, cfg.ContainerTimeout)
+			env := append(containerEnv(job.Env), metadataEnv(job)...)
+			containerLogs, err = stages.Run(job.DockerImage, repoDir, outputDir, env, sandboxFor(cfg, job.Resources), timeoutFor(cfg, job.Timeout))
 			return err
 		}},
 		{"collect", func() error { return stages.Collect(outputDir) }},
-		{"upload", func() error { return stages.Upload(s3Cfg, job.ProjectSlug, job.Version, outputDir) }},
+		{"validate", func() error {
+			if !cfg.ValidateOutput {
+				return nil
+			}
+			result, err := stages.Validate(outputDir, cfg.Validate)
+			if err != nil {
+				return err
+			}
+			warnings = append(warnings, result.Warnings...)
+			if len(result.Errors) > 0 {
+				return fmt.Errorf("%d problem(s): %s", len(result.Errors), strings.Join(result.Errors, "; "))
+			}
+			return nil
+		}},
+		{"upload", func() error {
+			files, err := stages.Manifest(outputDir)
+			if err != nil {
+				return err
+			}
+			if err := stages.Upload(store, job.ProjectSlug, job.Version, outputDir); err != nil {
+				return err
+			}
+			output.Files = files
+			// Untagged builds never become a version, so there is nothing
+			// to attach an archive to.
+			if job.Version == "" || cfg.ArchiveFormat == stages.ArchiveNone {
+				return nil
+			}
+			archivePath := filepath.Join(cfg.WorkspaceDir, "builder-archive-"+job.ID+"."+string(cfg.ArchiveFormat))
+			defer os.Remove(archivePath)
+			if err := stages.Archive(outputDir, archivePath, cfg.ArchiveFormat); err != nil {
+				return err
+			}
+			key := stages.ArchiveKey(job.ProjectSlug, job.Version, cfg.ArchiveFormat)
+			if err := stages.UploadFile(store, key, archivePath, cfg.ArchiveFormat.ContentType()); err != nil {
+				return err
+			}
+			output.Archive = key
+			return nil
+		}},
 	}
 
-	for _, s := range pipeline {
+	for i, s := range pipeline {
 		log.Printf("[%s] job %s: starting", s.name, job.ID)
-		if err := s.fn(); err != nil {
-			errMsg := fmt.Sprintf("%s: %v", s.name, err)
-			reportResult(cfg, job.ID, "failed", time.Since(start), errMsg, containerLogs)
+		started := time.Now()
+		err := s.fn()
+		finished := time.Now()
+		result := stageResult{Name: s.name, Status: "success", StartedAt: &started, FinishedAt: &finished}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		}
+		stageResults = append(stageResults, result)
+
+		if err != nil {
+			for _, rest := range pipeline[i+1:] {
+				stageResults = append(stageResults, stageResult{Name: rest.name, Status: "skipped"})
+			}
+			report(buildResult{Status: "failed", Error: fmt.Sprintf("%s: %v", s.name, err)})
 			return fmt.Errorf("%s: %w", s.name, err)
 		}
-		log.Printf("[%s] job %s: done", s.name, job.ID)
+		log.Printf("[%s] job %s: done in %s", s.name, job.ID, finished.Sub(started))
 	}
 
-	reportResult(cfg, job.ID, "success", time.Since(start), "", containerLogs)
+	report(buildResult{Status: "success", buildOutput: output})
 	log.Printf("job %s completed successfully in %s", job.ID, time.Since(start))
 	return nil
 }
+
+// metadataEnv describes the job to the build container.  This set of
+// variables is part of the builder image contract (see docs/builder-images.md):
+// rename or drop one and every image relying on it breaks.
+func metadataEnv(job Job) []string {
+	return []string{
+		"DOCTHOR_PROJECT=" + job.ProjectSlug,
+		"DOCTHOR_VERSION=" + job.Version,
+		"DOCTHOR_REF=" + job.Ref,
+		"DOCTHOR_COMMIT=" + job.Commit,
+		"DOCTHOR_BUILD_ID=" + job.ID,
+		"DOCTHOR_BASE_URL=" + job.BaseURL,
+		"DOCTHOR_VERSIONS=" + strings.Join(job.Versions, ","),
+		"DOCTHOR_LATEST_VERSION=" + job.Latest,
+	}
+}
+
+// containerEnv flattens a variable map into Docker's KEY=value form.  Keys are
+// sorted so the container sees a stable order from one build to the next.
+func containerEnv(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
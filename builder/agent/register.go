@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// version is reported to the server when registering; set at build time
+// with -ldflags "-X main.version=...".
+var version = "dev"
+
+// errUnauthorized means the server no longer accepts the builder's
+// credential.
+var errUnauthorized = errors.New("credential refused")
+
+// credential is the builder's current server token.  Registering again
+// replaces it while jobs started under the old one may still be running, so
+// it is read at each request.
+type credential struct {
+	mu    sync.RWMutex
+	token string
+}
+
+func (c *credential) get() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+func (c *credential) set(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// errRegistrationRejected means retrying is pointless: the registration
+// token or the builder's name or labels were refused, or an admin removed
+// the builder.
+type errRegistrationRejected struct{ status int }
+
+func (e errRegistrationRejected) Error() string {
+	return fmt.Sprintf("register: rejected with status %d", e.status)
+}
+
+// register exchanges the registration token for this builder's own
+// credential.  Registering again under the same name replaces it.
+func register(ctx context.Context, cfg Config) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"name":    cfg.Name,
+		"version": version,
+		"labels":  cfg.Labels,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.ServerURL+"/api/v1/builders/register", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.RegistrationToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized ||
+		resp.StatusCode == http.StatusForbidden {
+		return "", errRegistrationRejected{resp.StatusCode}
+	}
+	if resp.StatusCode == http.StatusConflict {
+		// A builder by this name is still online, or was until it stopped
+		// (a restart): retry once the server counts it offline.
+		return "", fmt.Errorf("register: name %q is in use by an online builder", cfg.Name)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("register: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.Token, nil
+}
+
+// maxBackoff caps the wait between registration attempts.
+const maxBackoff = 5 * time.Minute
+
+// mustRegister registers with the server, retrying with a growing wait
+// while it is unreachable (it may still be starting).
+func mustRegister(cfg Config) string {
+	wait := cfg.PollInterval
+	for {
+		token, err := register(context.Background(), cfg)
+		if err == nil {
+			log.Printf("registered with %s as %q", cfg.ServerURL, cfg.Name)
+			return token
+		}
+		if _, ok := err.(errRegistrationRejected); ok {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("register error: %v; retrying in %s", err, wait)
+		time.Sleep(wait)
+		wait = nextBackoff(wait, cfg.PollInterval)
+	}
+}
+
+// nextBackoff doubles wait, starting from base, up to maxBackoff.
+func nextBackoff(wait, base time.Duration) time.Duration {
+	if wait < base {
+		return base
+	}
+	return min(2*wait, maxBackoff)
+}
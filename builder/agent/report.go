@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/romain325/doc-thor/builder/agent/stages"
 )
 
 type buildResult struct {
@@ -15,28 +17,48 @@ type buildResult struct {
 	Duration string `json:"duration"`
 	Error    string `json:"error,omitempty"`
 	Logs     string `json:"logs,omitempty"`
+	// Warnings are problems that did not fail the build, e.g. broken links.
+	Warnings []string `json:"warnings,omitempty"`
+	// Stages records every pipeline stage in order, including those skipped
+	// after a failure.
+	Stages []stageResult `json:"stages,omitempty"`
+	buildOutput
+}
+
+// stageResult is the outcome of one pipeline stage.  Skipped stages have no
+// times.
+type stageResult struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"` // "success", "failed", or "skipped"
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// buildOutput describes where a successful build's output was stored.
+type buildOutput struct {
+	// Storage is the backend holding the files (storage.BackendS3, ...).
+	Storage string `json:"storage,omitempty"`
+	// Archive is the storage key of the downloadable archive, if one was made.
+	Archive string `json:"archive,omitempty"`
+	// Files is the manifest of the uploaded output.
+	Files []stages.FileEntry `json:"files,omitempty"`
 }
 
-func reportResult(cfg Config, jobID, status string, duration time.Duration, errMsg, logs string) {
-	body, err := json.Marshal(buildResult{
-		JobID:    jobID,
-		Status:   status,
-		Duration: duration.String(),
-		Error:    errMsg,
-		Logs:     logs,
-	})
+func reportResult(cfg Config, res buildResult) {
+	body, err := json.Marshal(res)
 	if err != nil {
 		log.Printf("report marshal: %v", err)
 		return
 	}
 
-	url := cfg.ServerURL + "/api/v1/builds/" + jobID + "/result"
+	url := cfg.ServerURL + "/api/v1/builds/" + res.JobID + "/result"
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		log.Printf("report request: %v", err)
 		return
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.ServerToken)
+	req.Header.Set("Authorization", "Bearer "+cfg.ServerToken.get())
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
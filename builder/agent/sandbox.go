@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	"github.com/romain325/doc-thor/builder/agent/stages"
+)
+
+// ResourceRequest is a project's requested build resources, as carried in the
+// job payload.  Zero/nil fields mean "use the builder default".
+type ResourceRequest struct {
+	MemoryMB int64   `json:"memory_mb,omitempty"`
+	CPUs     float64 `json:"cpus,omitempty"`
+	Pids     int64   `json:"pids,omitempty"`
+	Network  *bool   `json:"network,omitempty"`
+}
+
+// sandboxFor merges the builder defaults with a project's request and caps
+// the result at the builder maxima.  Projects can lower their limits freely
+// but never raise them past what the operator allows.
+func sandboxFor(cfg Config, req *ResourceRequest) stages.Sandbox {
+	if req == nil {
+		req = &ResourceRequest{}
+	}
+
+	network := cfg.BuildNetwork
+	if req.Network != nil {
+		network = *req.Network
+	}
+	if !cfg.BuildAllowNetwork {
+		network = false
+	}
+
+	memoryMB := capInt(pick(req.MemoryMB, cfg.BuildMemoryMB), cfg.BuildMaxMemoryMB)
+	cpus := cfg.BuildCPUs
+	if req.CPUs > 0 {
+		cpus = req.CPUs
+	}
+	if cfg.BuildMaxCPUs > 0 && (cpus <= 0 || cpus > cfg.BuildMaxCPUs) {
+		cpus = cfg.BuildMaxCPUs
+	}
+
+	return stages.Sandbox{
+		MemoryBytes:     memoryMB * 1024 * 1024,
+		NanoCPUs:        int64(cpus * 1e9),
+		PidsLimit:       capInt(pick(req.Pids, cfg.BuildPids), cfg.BuildMaxPids),
+		NetworkDisabled: !network,
+		ReadOnlyRootfs:  cfg.BuildReadOnlyRootfs,
+		TmpfsBytes:      cfg.BuildTmpfsMB * 1024 * 1024,
+		User:            cfg.BuildUser,
+		CapDrop:         cfg.BuildCapDrop,
+	}
+}
+
+// timeoutFor returns the container timeout for a job: the project's request
+// when set, the builder default otherwise, capped at the builder maximum.
+func timeoutFor(cfg Config, requestedSec int) time.Duration {
+	timeout := cfg.ContainerTimeout
+	if requestedSec > 0 {
+		timeout = time.Duration(requestedSec) * time.Second
+	}
+	if cfg.ContainerMaxTimeout > 0 && timeout > cfg.ContainerMaxTimeout {
+		timeout = cfg.ContainerMaxTimeout
+	}
+	return timeout
+}
+
+// pick returns the requested value when set, the default otherwise.
+func pick(requested, fallback int64) int64 {
+	if requested > 0 {
+		return requested
+	}
+	return fallback
+}
+
+// capInt clamps v to max.  A zero max means unlimited; a zero v with a
+// non-zero max means "unlimited requested", which is clamped to max as well.
+func capInt(v, max int64) int64 {
+	if max > 0 && (v <= 0 || v > max) {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package stages
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ArchiveFormat is the packaging of the downloadable copy of a build's output.
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+	ArchiveNone  ArchiveFormat = "none"
+)
+
+// ParseArchiveFormat validates an ARCHIVE_FORMAT value.
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	switch f := ArchiveFormat(s); f {
+	case ArchiveTarGz, ArchiveZip, ArchiveNone:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown archive format %q (want tar.gz, zip, or none)", s)
+}
+
+// ContentType is the MIME type the archive is stored with.
+func (f ArchiveFormat) ContentType() string {
+	if f == ArchiveZip {
+		return "application/zip"
+	}
+	return "application/gzip"
+}
+
+// ArchiveKey is where the archive of a version is stored: next to the
+// version's prefix rather than inside it, so it is not served as part of the
+// site.
+func ArchiveKey(projectSlug, version string, format ArchiveFormat) string {
+	return projectSlug + "/" + version + "." + string(format)
+}
+
+// Archive packs every file under outputDir into dest.  Entry names are
+// relative to outputDir, so the archive extracts to the site root.
+func Archive(outputDir, dest string, format ArchiveFormat) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer f.Close()
+
+	switch format {
+	case ArchiveTarGz:
+		err = writeTarGz(f, outputDir)
+	case ArchiveZip:
+		err = writeZip(f, outputDir)
+	default:
+		err = fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeTarGz(w io.Writer, root string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := walkFiles(root, func(rel string, info os.FileInfo, path string) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		if err := copyFile(tw, path); err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeZip(w io.Writer, root string) error {
+	zw := zip.NewWriter(w)
+
+	err := walkFiles(root, func(rel string, info os.FileInfo, path string) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		hdr.Name = rel
+		hdr.Method = zip.Deflate
+		entry, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		if err := copyFile(entry, path); err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,128 @@
+package stages
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/client"
+)
+
+// PullPolicy decides when EnsureImage contacts the registry.
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present"
+	PullNever        PullPolicy = "never"
+)
+
+// ParsePullPolicy validates a PULL_POLICY value.
+func ParsePullPolicy(s string) (PullPolicy, error) {
+	switch p := PullPolicy(s); p {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown pull policy %q (want always, if-not-present, or never)", s)
+}
+
+// EnsureImage makes image available to the local Docker daemon according to
+// policy.  authFile, when set, is a Docker config.json whose "auths" entry for
+// the image's registry is sent with the pull.
+func EnsureImage(image string, policy PullPolicy, authFile string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("docker client: %w", err)
+	}
+	defer cli.Close()
+
+	if policy != PullAlways {
+		_, err := cli.ImageInspect(ctx, image)
+		if err == nil {
+			return nil
+		}
+		if !cerrdefs.IsNotFound(err) {
+			return fmt.Errorf("image inspect: %w", err)
+		}
+		if policy == PullNever {
+			return fmt.Errorf("image %s is not present locally and pull policy is %q", image, policy)
+		}
+	}
+
+	auth, err := registryAuth(authFile, image)
+	if err != nil {
+		return err
+	}
+	resp, err := cli.ImagePull(ctx, image, client.ImagePullOptions{RegistryAuth: auth})
+	if err != nil {
+		return fmt.Errorf("image pull: %w", err)
+	}
+	defer resp.Close()
+	if err := resp.Wait(ctx); err != nil {
+		return fmt.Errorf("image pull: %w", err)
+	}
+	return nil
+}
+
+// registryAuth returns the encoded X-Registry-Auth value for image's registry
+// from a Docker config.json, or "" when there is no matching entry.
+func registryAuth(authFile, image string) (string, error) {
+	if authFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(authFile)
+	if err != nil {
+		return "", fmt.Errorf("read registry auth file: %w", err)
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("parse registry auth file: %w", err)
+	}
+
+	host := imageRegistry(image)
+	entry, ok := cfg.Auths[host]
+	if !ok && host == "docker.io" {
+		// docker login writes Docker Hub credentials under its legacy key.
+		entry, ok = cfg.Auths["https://index.docker.io/v1/"]
+	}
+	if !ok {
+		return "", nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return "", fmt.Errorf("registry auth for %s: %w", host, err)
+	}
+	user, pass, _ := strings.Cut(string(decoded), ":")
+	encoded, err := json.Marshal(map[string]string{
+		"username":      user,
+		"password":      pass,
+		"serveraddress": host,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+// imageRegistry returns the registry host of an image reference, defaulting
+// to Docker Hub like the Docker CLI does.
+func imageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
@@ -0,0 +1,52 @@
+package stages
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileEntry describes one file of a build's output.  Path is relative to the
+// site root and slash-separated.
+type FileEntry struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+}
+
+// Manifest lists every regular file under outputDir with its size, SHA-256
+// and the content type it is uploaded with, in lexical path order.
+func Manifest(outputDir string) ([]FileEntry, error) {
+	var files []FileEntry
+	err := walkFiles(outputDir, func(rel string, _ os.FileInfo, path string) error {
+		size, sum, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", rel, err)
+		}
+		files = append(files, FileEntry{
+			Path:        rel,
+			Size:        size,
+			SHA256:      sum,
+			ContentType: contentTypeFor(path),
+		})
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -39,3 +39,15 @@ func Pull(sourceURL, ref, repoDir string) (string, error) {
 	}
 	return string(bytes.TrimSpace(out)), nil
 }
+
+// HeadCommit returns the full SHA of the commit checked out in repoDir.
+func HeadCommit(repoDir string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("resolve HEAD commit: %w", err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
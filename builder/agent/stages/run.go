@@ -4,18 +4,68 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/client"
 )
 
+// Sandbox is the isolation applied to a build container.  Zero numeric values
+// mean "no limit".
+type Sandbox struct {
+	MemoryBytes     int64
+	NanoCPUs        int64
+	PidsLimit       int64
+	NetworkDisabled bool
+	// ReadOnlyRootfs mounts the image filesystem read-only.  /tmp is then
+	// backed by a tmpfs of TmpfsBytes so tools still have scratch space.
+	ReadOnlyRootfs bool
+	TmpfsBytes     int64
+	User           string
+	CapDrop        []string
+}
+
+// hostConfig translates the sandbox into Docker host settings.  Privilege
+// escalation (setuid binaries) is always blocked.
+func (s Sandbox) hostConfig(repoDir, outputDir string) *container.HostConfig {
+	hc := &container.HostConfig{
+		Binds: []string{
+			repoDir + ":/repo:ro",
+			outputDir + ":/output:rw",
+		},
+		CapDrop:        s.CapDrop,
+		SecurityOpt:    []string{"no-new-privileges"},
+		ReadonlyRootfs: s.ReadOnlyRootfs,
+		Resources: container.Resources{
+			Memory:     s.MemoryBytes,
+			MemorySwap: s.MemoryBytes, // no swap on top of the memory limit
+			NanoCPUs:   s.NanoCPUs,
+		},
+	}
+	if s.PidsLimit > 0 {
+		hc.Resources.PidsLimit = &s.PidsLimit
+	}
+	if s.NetworkDisabled {
+		hc.NetworkMode = "none"
+	}
+	if s.ReadOnlyRootfs {
+		opts := "rw,noexec,nosuid"
+		if s.TmpfsBytes > 0 {
+			opts += ",size=" + strconv.FormatInt(s.TmpfsBytes, 10)
+		}
+		hc.Tmpfs = map[string]string{"/tmp": opts}
+	}
+	return hc
+}
+
 // Run starts the user-supplied image with the cloned repo mounted read-only at
-// /repo and outputDir mounted read-write at /output. It waits for the container
-// to exit and enforces timeout as a hard cap. The container is removed on
-// return regardless of outcome. The combined stdout+stderr log output is always
+// /repo and outputDir mounted read-write at /output, and env (KEY=value pairs)
+// as its environment, confined by sandbox. It waits for the container to exit
+// and enforces timeout as a hard cap. The container is removed on return
+// regardless of outcome. The combined stdout+stderr log output is always
 // returned (even on error) so callers can surface it.
-func Run(image, repoDir, outputDir string, timeout time.Duration) (string, error) {
+func Run(image, repoDir, outputDir string, env []string, sandbox Sandbox, timeout time.Duration) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -26,13 +76,13 @@ func Run(image, repoDir, outputDir string, timeout time.Duration) (string, error
 	defer cli.Close()
 
 	createResp, err := cli.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config: &container.Config{Image: image},
-		HostConfig: &container.HostConfig{
-			Binds: []string{
-				repoDir + ":/repo:ro",
-				outputDir + ":/output:rw",
-			},
+		Config: &container.Config{
+			Image:           image,
+			Env:             env,
+			User:            sandbox.User,
+			NetworkDisabled: sandbox.NetworkDisabled,
 		},
+		HostConfig: sandbox.hostConfig(repoDir, outputDir),
 	})
 	if err != nil {
 		return "", fmt.Errorf("container create: %w", err)
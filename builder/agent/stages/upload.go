@@ -7,67 +7,61 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/romain325/doc-thor/builder/agent/storage"
 )
 
-// S3Config is the connection surface for the S3-compatible storage backend.
-type S3Config struct {
-	Endpoint  string
-	Region    string
-	AccessKey string
-	SecretKey string
-	Bucket    string
-}
-
-// Upload walks outputDir and PutObjects every file into the bucket under the
-// path <projectSlug>/<version>/<relative-path>, which is the storage contract
-// shared by all doc-thor modules.
-func Upload(cfg S3Config, projectSlug, version, outputDir string) error {
-	s3Client := s3.NewFromConfig(aws.Config{
-		Region: cfg.Region,
-		Credentials: credentials.NewStaticCredentialsProvider(
-			cfg.AccessKey, cfg.SecretKey, "",
-		),
-	}, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(cfg.Endpoint)
-		o.UsePathStyle = true
-	})
-
-	return filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
+// Upload walks outputDir and Puts every regular file into store under the path
+// <projectSlug>/<version>/<relative-path>, which is the storage contract
+// shared by all doc-thor modules.  Objects left under that prefix by a
+// previous build of the same version are deleted afterwards, so pages the new
+// build no longer produces stop being served.
+func Upload(store storage.Backend, projectSlug, version, outputDir string) error {
+	ctx := context.Background()
+	prefix := projectSlug + "/" + version + "/"
+	uploaded := map[string]bool{}
 
-		rel, err := filepath.Rel(outputDir, path)
-		if err != nil {
+	err := walkFiles(outputDir, func(rel string, _ os.FileInfo, path string) error {
+		key := prefix + rel
+		if err := UploadFile(store, key, path, contentTypeFor(path)); err != nil {
 			return err
 		}
+		uploaded[key] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		f, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("open %s: %w", rel, err)
+	existing, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, key := range existing {
+		if !uploaded[key] {
+			stale = append(stale, key)
 		}
-		defer f.Close()
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return store.Delete(ctx, stale...)
+}
 
-		key := projectSlug + "/" + version + "/" + filepath.ToSlash(rel)
-		contentType := mime.TypeByExtension(filepath.Ext(path))
-		if contentType == "" {
-			contentType = "application/octet-stream"
-		}
-		if _, err := s3Client.PutObject(context.Background(), &s3.PutObjectInput{
-			Bucket:      aws.String(cfg.Bucket),
-			Key:         aws.String(key),
-			Body:        f,
-			ContentType: aws.String(contentType),
-		}); err != nil {
-			return fmt.Errorf("put %s: %w", key, err)
-		}
+// UploadFile Puts a single file under key.
+func UploadFile(store storage.Backend, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	return store.Put(context.Background(), key, f, contentType)
+}
 
-		return nil
-	})
+// contentTypeFor guesses a file's MIME type from its extension.
+func contentTypeFor(path string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(path)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
 }
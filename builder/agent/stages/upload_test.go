@@ -0,0 +1,68 @@
+package stages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/romain325/doc-thor/builder/agent/storage"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadSkipsSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	secret := filepath.Join(tmp, "secret")
+	writeFile(t, secret, "host file")
+
+	site := filepath.Join(tmp, "site")
+	writeFile(t, filepath.Join(site, "index.html"), "<h1>docs</h1>")
+	writeFile(t, filepath.Join(site, "guide", "intro.html"), "intro")
+	if err := os.Symlink(secret, filepath.Join(site, "leak.html")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(filepath.Dir(secret), filepath.Join(site, "host")); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := storage.NewLocal(filepath.Join(tmp, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Upload(store, "proj", "v1", site); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	keys, err := store.List(context.Background(), "proj/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	want := []string{"proj/v1/guide/intro.html", "proj/v1/index.html"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("uploaded %v, want %v", keys, want)
+	}
+
+	files, err := Manifest(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"guide/intro.html", "index.html"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("manifest lists %v, want %v", paths, want)
+	}
+}
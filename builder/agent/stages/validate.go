@@ -0,0 +1,185 @@
+package stages
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LinkCheck sets how broken internal links and missing assets are reported.
+type LinkCheck string
+
+const (
+	LinksError LinkCheck = "error"
+	LinksWarn  LinkCheck = "warn"
+	LinksOff   LinkCheck = "off"
+)
+
+// ParseLinkCheck validates a VALIDATE_LINKS value.
+func ParseLinkCheck(s string) (LinkCheck, error) {
+	switch l := LinkCheck(s); l {
+	case LinksError, LinksWarn, LinksOff:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown link check mode %q (want error, warn, or off)", s)
+}
+
+// ValidateConfig holds the output limits and checks.  Zero limits are
+// unlimited.
+type ValidateConfig struct {
+	MaxBytes int64
+	MaxFiles int
+	Links    LinkCheck
+}
+
+// ValidationReport separates problems that block publishing (Errors) from
+// those that are only recorded on the build (Warnings).
+type ValidationReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// maxLinkProblems bounds how many broken links are listed individually; a
+// site with a broken template would otherwise produce one line per page.
+const maxLinkProblems = 50
+
+// attrPattern matches href/src attribute values, quoted or not.
+var attrPattern = regexp.MustCompile(`(?i)\s(href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// Validate checks the site in outputDir before it is uploaded: an index.html
+// at the root, the size and file-count limits, and (unless disabled) every
+// internal href/src of every HTML page.
+func Validate(outputDir string, cfg ValidateConfig) (ValidationReport, error) {
+	var report ValidationReport
+
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); err != nil {
+		report.Errors = append(report.Errors, "index.html is missing at the output root")
+	}
+
+	var total int64
+	var files int
+	var pages []string
+	err := filepath.WalkDir(outputDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		total += info.Size()
+		if ext := strings.ToLower(filepath.Ext(p)); ext == ".html" || ext == ".htm" {
+			pages = append(pages, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("walk output: %w", err)
+	}
+
+	if cfg.MaxFiles > 0 && files > cfg.MaxFiles {
+		report.Errors = append(report.Errors, fmt.Sprintf("output has %d files, limit is %d", files, cfg.MaxFiles))
+	}
+	if cfg.MaxBytes > 0 && total > cfg.MaxBytes {
+		report.Errors = append(report.Errors, fmt.Sprintf("output is %d bytes, limit is %d", total, cfg.MaxBytes))
+	}
+
+	if cfg.Links == LinksOff {
+		return report, nil
+	}
+	var problems []string
+	for _, page := range pages {
+		found, err := checkLinks(outputDir, page)
+		if err != nil {
+			return report, err
+		}
+		problems = append(problems, found...)
+	}
+	if len(problems) > maxLinkProblems {
+		problems = append(problems[:maxLinkProblems], fmt.Sprintf("... and %d more", len(problems)-maxLinkProblems))
+	}
+	if cfg.Links == LinksError {
+		report.Errors = append(report.Errors, problems...)
+	} else {
+		report.Warnings = append(report.Warnings, problems...)
+	}
+	return report, nil
+}
+
+// checkLinks returns one message per internal href/src in page that does not
+// resolve to a file of the output.
+func checkLinks(root, page string) ([]string, error) {
+	data, err := os.ReadFile(page)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", page, err)
+	}
+	rel, err := filepath.Rel(root, page)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	var problems []string
+	seen := map[string]bool{}
+	for _, m := range attrPattern.FindAllStringSubmatch(string(data), -1) {
+		ref := m[2] + m[3] + m[4]
+		target, ok := internalTarget(path.Dir(rel), ref)
+		if !ok || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if exists(root, target) {
+			continue
+		}
+		kind := "broken link"
+		if strings.EqualFold(m[1], "src") {
+			kind = "missing asset"
+		}
+		problems = append(problems, fmt.Sprintf("%s in %s: %s", kind, rel, ref))
+	}
+	return problems, nil
+}
+
+// internalTarget resolves ref, found in a page under dir, to a path relative
+// to the site root.  ok is false for external URLs, fragments, and anything
+// else that does not point into the output.
+func internalTarget(dir, ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") ||
+		strings.Contains(ref, "{{") || strings.Contains(ref, "${") {
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	// Docs are served at the root of their subdomain, so "/x" is the output's x.
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join("/", dir, p)
+	}
+	return strings.TrimPrefix(path.Clean(p), "/"), true
+}
+
+// exists reports whether target is served by the site: a file, or a
+// directory with an index.html.
+func exists(root, target string) bool {
+	p := filepath.Join(root, filepath.FromSlash(target))
+	info, err := os.Stat(p)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		_, err := os.Stat(filepath.Join(p, "index.html"))
+		return err == nil
+	}
+	return true
+}
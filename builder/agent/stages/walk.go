@@ -0,0 +1,30 @@
+package stages
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// walkFiles calls fn for every regular file under root with its slash-separated
+// relative path.  Symlinks and other special files are skipped: the build
+// container wrote them, and following one could publish, archive or hash a
+// file of the builder host.
+func walkFiles(root string, fn func(rel string, info os.FileInfo, path string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info, path)
+	})
+}
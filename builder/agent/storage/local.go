@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type localBackend struct {
+	root string
+}
+
+// NewLocal returns a backend storing objects as files under root, for
+// deployments where nginx serves the same directory straight from disk.
+func NewLocal(root string) (Backend, error) {
+	if root == "" {
+		return nil, errors.New("local storage: root directory not set")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("local storage: %w", err)
+	}
+	return &localBackend{root: root}, nil
+}
+
+func (b *localBackend) Name() string { return BackendLocal }
+
+// path maps a key to a file under root, refusing keys that would escape it.
+func (b *localBackend) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes to a temp file and renames it into place so nginx never serves a
+// half-written file.  Files are world-readable: nginx runs as another user.
+func (b *localBackend) Put(_ context.Context, key string, body io.Reader, _ string) error {
+	dest, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".put-*")
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *localBackend) List(_ context.Context, prefix string) ([]string, error) {
+	// Walk the deepest directory fully covered by prefix, then filter.
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	start := filepath.Join(b.root, filepath.FromSlash(filepath.Clean("/"+dir)))
+
+	var keys []string
+	err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+func (b *localBackend) Delete(_ context.Context, keys ...string) error {
+	for _, key := range keys {
+		path, err := b.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
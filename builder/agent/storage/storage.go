@@ -0,0 +1,48 @@
+// Package storage abstracts where the builder puts build output.  Keys follow
+// the storage contract shared by all doc-thor modules:
+// <slug>/<version>/<relative-path>.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Backend names, as reported to the server with each build result so it can
+// route a version to wherever its files live.
+const (
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// Backend is a flat key/value object store.
+type Backend interface {
+	// Name returns the backend identifier (BackendS3, BackendLocal).
+	Name() string
+	// Put stores body under key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// List returns every key starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the given keys.  Keys that do not exist are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	Backend string
+	S3      S3Config
+	// LocalDir is the root directory of the local backend.
+	LocalDir string
+}
+
+// New returns the backend selected by cfg.Backend.
+func New(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case BackendS3:
+		return NewS3(cfg.S3), nil
+	case BackendLocal:
+		return NewLocal(cfg.LocalDir)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (want %s or %s)", cfg.Backend, BackendS3, BackendLocal)
+}
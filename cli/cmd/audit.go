@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFilter client.AuditFilter
+	auditSince  string
+	auditUntil  string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log (admin)",
+	Long: `Every change made through the API is recorded with who made it, what it
+changed and where the request came from, along with sign-ins.  Refused and
+failed attempts are recorded too, with the HTTP status they got; --failed
+shows only those.  Newest first.
+
+--action matches a whole action or a family ("version" matches
+"version.update"); --target matches a resource and everything under it
+("projects/docs" matches "projects/docs/versions/1.2.0").  --since and
+--until take a time (2024-05-01, 2024-05-01T12:00:00Z) or an age (24h, 7d).`,
+	Example: `  doc-thor audit --target projects/docs --action version
+  doc-thor audit --actor alice --since 7d
+  doc-thor audit --action auth --failed`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if auditFilter.Since, err = auditTime(auditSince); err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		if auditFilter.Until, err = auditTime(auditUntil); err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+
+		events, err := c.ListAuditEvents(auditFilter)
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(events)
+		}
+		rows := make([][]string, len(events))
+		for i, e := range events {
+			actor := e.Actor
+			if e.APIKeyID != nil {
+				actor += fmt.Sprintf(" (key %d)", *e.APIKeyID)
+			}
+			rows[i] = []string{e.CreatedAt, actor, e.Action, e.Target, strconv.Itoa(e.Status), changeStr(e), orDash(e.SourceIP)}
+		}
+		ui.PrintTable([]string{"Time", "Actor", "Action", "Target", "Status", "Change", "From"}, rows)
+		return nil
+	},
+}
+
+// auditTime turns a date, an RFC 3339 time or an age ("24h", "7d") into an
+// RFC 3339 time.
+func auditTime(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format(time.RFC3339), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.Format(time.RFC3339), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n).Format(time.RFC3339), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d).Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("%q is not a date, time or age", s)
+}
+
+// changeStr shows what an update changed; creations and deletions are
+// told apart by their action.
+func changeStr(e client.AuditEvent) string {
+	if e.Before == "" || e.After == "" {
+		return "-"
+	}
+	return e.Before + " → " + e.After
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringVar(&auditFilter.Actor, "actor", "", "only changes by this user")
+	auditCmd.Flags().StringVar(&auditFilter.Action, "action", "", "only this action or family of actions")
+	auditCmd.Flags().StringVar(&auditFilter.Target, "target", "", "only changes to this resource and what is under it")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "only events at or after this time or age")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "only events before this time or age")
+	auditCmd.Flags().BoolVar(&auditFilter.Failed, "failed", false, "only refused and failed attempts")
+	auditCmd.Flags().IntVar(&auditFilter.Limit, "limit", 50, "max number of events")
+	auditCmd.Flags().IntVar(&auditFilter.Offset, "offset", 0, "number of events to skip")
+}
@@ -2,19 +2,41 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/romain325/doc-thor/cli/internal/client"
 	"github.com/romain325/doc-thor/cli/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var apikeyLabel string
+var (
+	apikeyLabel       string
+	apikeyScopes      []string
+	apikeyProject     string
+	apikeyExpiresDays int
+)
 
 var apikeyCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new API key",
+	Long: `Create a new API key.
+
+Without --scope the key acts with all of your permissions.  With one or
+more scopes it can read everything you can but only perform the listed
+writes:
+
+  builds:trigger     trigger builds
+  versions:publish   publish and unpublish versions
+  projects:write     update project settings, variables and schedules
+
+--project restricts the key to a single project.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		key, err := c.CreateAPIKey(client.APIKeyCreateRequest{Label: apikeyLabel})
+		key, err := c.CreateAPIKey(client.APIKeyCreateRequest{
+			Label:         apikeyLabel,
+			Scopes:        apikeyScopes,
+			Project:       apikeyProject,
+			ExpiresInDays: apikeyExpiresDays,
+		})
 		if err != nil {
 			return err
 		}
@@ -24,14 +46,36 @@ var apikeyCreateCmd = &cobra.Command{
 		ui.Success("API key created.")
 		fmt.Println(ui.WarningStyle.Render("This key is shown only once — store it safely."))
 		ui.DetailCard("API Key", [][]string{
+			{"ID", fmt.Sprint(key.ID)},
 			{"Label", key.Label},
+			{"Scopes", scopesStr(key.Scopes)},
+			{"Project", orDash(key.Project)},
+			{"Expires", expiresStr(key.ExpiresAt)},
 			{"Key", key.Key},
 		})
 		return nil
 	},
 }
 
+// scopesStr renders an API key's scopes; an unscoped key has full access.
+func scopesStr(scopes []string) string {
+	if len(scopes) == 0 {
+		return "all"
+	}
+	return strings.Join(scopes, ",")
+}
+
+func expiresStr(s string) string {
+	if s == "" {
+		return "never"
+	}
+	return s
+}
+
 func init() {
 	apikeyCmd.AddCommand(apikeyCreateCmd)
 	apikeyCreateCmd.Flags().StringVar(&apikeyLabel, "label", "", "human-readable label")
+	apikeyCreateCmd.Flags().StringSliceVar(&apikeyScopes, "scope", nil, "restrict the key to a scope (repeatable)")
+	apikeyCreateCmd.Flags().StringVar(&apikeyProject, "project", "", "restrict the key to a project slug")
+	apikeyCreateCmd.Flags().IntVar(&apikeyExpiresDays, "expires-days", 0, "expire the key after this many days (0 = never)")
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your API keys",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, err := c.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(keys)
+		}
+		rows := make([][]string, len(keys))
+		for i, k := range keys {
+			rows[i] = []string{
+				fmt.Sprint(k.ID), orDash(k.Label), scopesStr(k.Scopes), orDash(k.Project),
+				expiresStr(k.ExpiresAt), orDash(k.LastUsedAt), k.CreatedAt,
+			}
+		}
+		ui.PrintTable([]string{"ID", "Label", "Scopes", "Project", "Expires", "Last Used", "Created"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	apikeyCmd.AddCommand(apikeyListCmd)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke [key-id]",
+	Short: "Revoke one of your API keys",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid api key id: %s", args[0])
+		}
+		if err := c.RevokeAPIKey(uint(id)); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]uint64{"revoked": id})
+		}
+		ui.Success("API key revoked.")
+		return nil
+	},
+}
+
+func init() {
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+}
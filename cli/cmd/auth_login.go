@@ -13,12 +13,30 @@ import (
 var (
 	loginUsername string
 	loginPassword string
+	loginSSO      bool
 )
 
 var authLoginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate and save session token",
+	Long: `Authenticate and save a session token.
+
+With --sso, sign in through the server's identity provider instead: the
+CLI opens the login page in your browser and waits for it to send the
+session token back to a temporary listener on localhost.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		url := cfg.Server.URL
+		if url == "" {
+			url = "http://localhost:8000"
+		}
+		if loginSSO {
+			token, err := ssoLogin(client.New(url, "").BaseURL())
+			if err != nil {
+				return err
+			}
+			return saveLogin(client.LoginResponse{Token: token})
+		}
+
 		if !ui.JSON {
 			if err := huh.NewForm(
 				huh.NewGroup(
@@ -39,10 +57,6 @@ var authLoginCmd = &cobra.Command{
 			}
 		}
 
-		url := cfg.Server.URL
-		if url == "" {
-			url = "http://localhost:8000"
-		}
 		loginClient := client.New(url, "") // no token yet
 		resp, err := loginClient.Login(client.LoginRequest{
 			Username: loginUsername,
@@ -51,22 +65,26 @@ var authLoginCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		return saveLogin(resp)
+	},
+}
 
-		cfg.Server.APIKey = resp.Token
-		if err := config.Save(cfg); err != nil {
-			return fmt.Errorf("failed to save token: %w", err)
-		}
+func saveLogin(resp client.LoginResponse) error {
+	cfg.Server.APIKey = resp.Token
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
 
-		if ui.JSON {
-			return ui.PrintJSON(resp)
-		}
-		ui.Success("Logged in. Token saved.")
-		return nil
-	},
+	if ui.JSON {
+		return ui.PrintJSON(resp)
+	}
+	ui.Success("Logged in. Token saved.")
+	return nil
 }
 
 func init() {
 	authCmd.AddCommand(authLoginCmd)
 	authLoginCmd.Flags().StringVar(&loginUsername, "username", "", "username")
 	authLoginCmd.Flags().StringVar(&loginPassword, "password", "", "password")
+	authLoginCmd.Flags().BoolVar(&loginSSO, "sso", false, "sign in through the server's identity provider in a browser")
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/config"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "End the current session and forget its token",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfg.Server.APIKey == "" {
+			return fmt.Errorf("not logged in")
+		}
+		// An expired session is already gone server-side; just forget it.
+		var apiErr *client.APIError
+		if err := c.Logout(); err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized) {
+			return err
+		}
+
+		cfg.Server.APIKey = ""
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("failed to clear token: %w", err)
+		}
+
+		if ui.JSON {
+			return ui.PrintJSON(map[string]bool{"logged_out": true})
+		}
+		ui.Success("Logged out.")
+		return nil
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authLogoutCmd)
+}
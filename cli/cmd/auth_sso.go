@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// ssoTimeout bounds how long the CLI waits for the browser login.
+const ssoTimeout = 5 * time.Minute
+
+// ssoLogin runs the server's browser login flow and returns the session
+// token.  The server redirects the browser back to a one-shot listener on
+// the loopback interface with either ?token= or ?error=.
+func ssoLogin(apiBase string) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start callback listener: %w", err)
+	}
+	callback := fmt.Sprintf("http://%s/callback", ln.Addr())
+
+	type result struct {
+		token string
+		err   error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		res := result{token: q.Get("token")}
+		if e := q.Get("error"); e != "" || res.token == "" {
+			res.err = fmt.Errorf("sso login failed: %s", e)
+			fmt.Fprintln(w, "doc-thor login failed: "+e)
+		} else {
+			fmt.Fprintln(w, "doc-thor login complete. You can close this window.")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Shutdown(context.Background())
+
+	loginURL := apiBase + "/auth/oidc/login?redirect_uri=" + url.QueryEscape(callback)
+	fmt.Fprintln(os.Stderr, "Opening your browser to sign in. If it does not open, visit:")
+	fmt.Fprintln(os.Stderr, "  "+loginURL)
+	openBrowser(loginURL)
+
+	select {
+	case res := <-done:
+		return res.token, res.err
+	case <-time.After(ssoTimeout):
+		return "", errors.New("timed out waiting for the sso login")
+	}
+}
+
+// openBrowser tries to open u in the default browser; failure is fine, the
+// URL has been printed.
+func openBrowser(u string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	_ = cmd.Start()
+}
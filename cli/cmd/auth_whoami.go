@@ -21,6 +21,7 @@ var authWhoamiCmd = &cobra.Command{
 		ui.DetailCard("User", [][]string{
 			{"ID", fmt.Sprint(user.ID)},
 			{"Username", user.Username},
+			{"Role", user.Role},
 			{"Created", user.CreatedAt},
 		})
 		return nil
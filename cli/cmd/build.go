@@ -24,6 +24,10 @@ func buildPairs(b client.Build) [][]string {
 		{"Project ID", fmt.Sprint(b.ProjectID)},
 		{"Ref", orDash(b.Ref)},
 		{"Status", ui.StatusBadge(b.Status)},
+		{"Trigger", orDash(b.Trigger)},
+	}
+	if b.Commit != "" {
+		pairs = append(pairs, []string{"Commit", b.Commit})
 	}
 	if b.StartedAt != "" {
 		pairs = append(pairs, []string{"Started", b.StartedAt})
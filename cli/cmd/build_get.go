@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/romain325/doc-thor/cli/internal/client"
 	"github.com/romain325/doc-thor/cli/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -25,13 +28,60 @@ var buildGetCmd = &cobra.Command{
 			return ui.PrintJSON(build)
 		}
 		ui.DetailCard("Build", buildPairs(build))
+		if len(build.Stages) > 0 {
+			printStageTimeline(build.Stages)
+		}
+		if build.Error != "" {
+			fmt.Println(ui.ErrorStyle.Render("Error: " + build.Error))
+		}
+		for _, w := range build.Warnings {
+			fmt.Println(ui.WarningStyle.Render("Warning: " + w))
+		}
 		if build.Logs != "" {
 			fmt.Println(ui.LogsStyle.Render(build.Logs))
 		}
+		if build.LogSize > int64(len(build.Logs)) {
+			fmt.Println(ui.WarningStyle.Render(fmt.Sprintf("Showing the last %d of %d bytes; run `doc-thor build logs %s %d` for the full log.", len(build.Logs), build.LogSize, args[0], build.ID)))
+		}
 		return nil
 	},
 }
 
+// timelineWidth is the width of the bar of the longest stage.
+const timelineWidth = 30
+
+// printStageTimeline shows each stage with its status, duration, and a bar
+// proportional to the time it took.
+func printStageTimeline(stages []client.BuildStage) {
+	durations := make([]time.Duration, len(stages))
+	var longest time.Duration
+	for i, st := range stages {
+		start, err1 := time.Parse(time.RFC3339Nano, st.StartedAt)
+		end, err2 := time.Parse(time.RFC3339Nano, st.FinishedAt)
+		if err1 == nil && err2 == nil {
+			durations[i] = end.Sub(start)
+			longest = max(longest, durations[i])
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(ui.TitleStyle.Render("Stages"))
+	for i, st := range stages {
+		took, bar := "-", ""
+		if st.Status != "skipped" {
+			took = durations[i].Round(time.Millisecond).String()
+			if longest > 0 {
+				bar = strings.Repeat("█", max(1, int(timelineWidth*durations[i]/longest)))
+			}
+		}
+		status := ui.StatusBadge(st.Status) + strings.Repeat(" ", max(0, 8-len(st.Status)))
+		fmt.Printf("  %-10s %s %10s  %s\n", st.Name, status, took, bar)
+		if st.Error != "" {
+			fmt.Println(ui.ErrorStyle.Render("    " + st.Error))
+		}
+	}
+}
+
 func init() {
 	buildCmd.AddCommand(buildGetCmd)
 }
@@ -26,9 +26,9 @@ var buildListCmd = &cobra.Command{
 		}
 		rows := make([][]string, len(builds))
 		for i, b := range builds {
-			rows[i] = []string{fmt.Sprint(b.ID), orDash(b.Ref), b.Status, b.CreatedAt}
+			rows[i] = []string{fmt.Sprint(b.ID), orDash(b.Ref), b.Status, orDash(b.Trigger), b.CreatedAt}
 		}
-		ui.PrintTable([]string{"ID", "Ref", "Status", "Created"}, rows)
+		ui.PrintTable([]string{"ID", "Ref", "Status", "Trigger", "Created"}, rows)
 		return nil
 	},
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// logChunkSize is how much of the log is fetched per request.
+const logChunkSize = 256 << 10
+
+var buildLogsCmd = &cobra.Command{
+	Use:   "logs [slug] [build-id]",
+	Short: "Print the full log of a build",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid build id: %s", args[1])
+		}
+		var offset int64
+		for {
+			chunk, err := c.GetBuildLogs(args[0], uint(id), offset, logChunkSize)
+			if err != nil {
+				return err
+			}
+			fmt.Print(chunk.Content)
+			if chunk.NextOffset >= chunk.Total || chunk.NextOffset == offset {
+				return nil
+			}
+			offset = chunk.NextOffset
+		}
+	},
+}
+
+func init() {
+	buildCmd.AddCommand(buildLogsCmd)
+}
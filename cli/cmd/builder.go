@@ -0,0 +1,15 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var builderCmd = &cobra.Command{
+	Use:   "builder",
+	Short: "Inspect and remove registered builders",
+	Long: `Builders register themselves with the server's BUILDER_REGISTRATION_TOKEN
+and receive a credential of their own.  A builder that stops polling for
+longer than BUILDER_OFFLINE_AFTER_SECONDS is shown offline.`,
+}
+
+func init() {
+	rootCmd.AddCommand(builderCmd)
+}
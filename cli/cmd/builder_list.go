@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var builderListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered builders",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		builders, err := c.ListBuilders()
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(builders)
+		}
+		rows := make([][]string, len(builders))
+		for i, b := range builders {
+			status := "offline"
+			if b.Online {
+				status = "online"
+			}
+			jobs := make([]string, len(b.CurrentJobs))
+			for j, id := range b.CurrentJobs {
+				jobs[j] = fmt.Sprint(id)
+			}
+			rows[i] = []string{
+				fmt.Sprint(b.ID), b.Name, status, orDash(b.Version),
+				orDash(strings.Join(b.Labels, ",")), orDash(b.LastSeenAt), orDash(strings.Join(jobs, ",")),
+			}
+		}
+		ui.PrintTable([]string{"ID", "Name", "Status", "Version", "Labels", "Last Seen", "Current Jobs"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	builderCmd.AddCommand(builderListCmd)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var builderRemoveCmd = &cobra.Command{
+	Use:   "remove [builder-id]",
+	Short: "Remove a builder and revoke its credential",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid builder id: %s", args[0])
+		}
+		if err := c.DeleteBuilder(uint(id)); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]uint64{"deleted": id})
+		}
+		ui.Success("Builder removed.")
+		return nil
+	},
+}
+
+func init() {
+	builderCmd.AddCommand(builderRemoveCmd)
+}
@@ -1,6 +1,12 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/spf13/cobra"
+)
 
 var projectCmd = &cobra.Command{
 	Use:   "project",
@@ -10,3 +16,81 @@ var projectCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(projectCmd)
 }
+
+// resourceFlags holds the build resource flags shared by create and update.
+type resourceFlags struct {
+	memoryMB int64
+	cpus     float64
+	pids     int64
+	network  bool
+}
+
+func (f *resourceFlags) register(cmd *cobra.Command) {
+	cmd.Flags().Int64Var(&f.memoryMB, "memory-mb", 0, "build container memory limit in MiB (capped by the builder)")
+	cmd.Flags().Float64Var(&f.cpus, "cpus", 0, "build container CPU limit (capped by the builder)")
+	cmd.Flags().Int64Var(&f.pids, "pids", 0, "build container process limit (capped by the builder)")
+	cmd.Flags().BoolVar(&f.network, "network", true, "allow the build container to reach the network")
+}
+
+// request returns the resources set on the command line, or nil when none
+// of the resource flags were given.
+func (f *resourceFlags) request(cmd *cobra.Command) *client.BuildResources {
+	changed := false
+	r := &client.BuildResources{}
+	if cmd.Flags().Changed("memory-mb") {
+		r.MemoryMB = f.memoryMB
+		changed = true
+	}
+	if cmd.Flags().Changed("cpus") {
+		r.CPUs = f.cpus
+		changed = true
+	}
+	if cmd.Flags().Changed("pids") {
+		r.Pids = f.pids
+		changed = true
+	}
+	if cmd.Flags().Changed("network") {
+		network := f.network
+		r.Network = &network
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r
+}
+
+// resourcesStr renders a project's resource request for detail cards.
+func resourcesStr(r *client.BuildResources) string {
+	if r == nil {
+		return "builder defaults"
+	}
+	s := fmt.Sprintf("memory=%s cpus=%s pids=%s", limitStr(r.MemoryMB), limitStr(r.CPUs), limitStr(r.Pids))
+	if r.Network != nil {
+		s += " network=" + boolStr(*r.Network)
+	}
+	return s
+}
+
+// timeoutStr renders a project's build timeout for detail cards.
+func timeoutStr(seconds int) string {
+	if seconds == 0 {
+		return "builder default"
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// labelsStr renders a project's required builder labels for detail cards.
+func labelsStr(labels []string) string {
+	if len(labels) == 0 {
+		return "any builder"
+	}
+	return strings.Join(labels, ", ")
+}
+
+func limitStr[T int64 | float64](v T) string {
+	if v == 0 {
+		return "default"
+	}
+	return fmt.Sprint(v)
+}
@@ -13,6 +13,10 @@ var (
 	createName        string
 	createSourceURL   string
 	createDockerImage string
+	createResources   resourceFlags
+	createTimeout     int
+	createLabels      []string
+	createVisibility  string
 )
 
 var projectCreateCmd = &cobra.Command{
@@ -20,10 +24,14 @@ var projectCreateCmd = &cobra.Command{
 	Short: "Create a new project",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		req := client.ProjectCreate{
-			Slug:        createSlug,
-			Name:        createName,
-			SourceURL:   createSourceURL,
-			DockerImage: createDockerImage,
+			Slug:           createSlug,
+			Name:           createName,
+			SourceURL:      createSourceURL,
+			DockerImage:    createDockerImage,
+			Resources:      createResources.request(cmd),
+			BuildTimeout:   createTimeout,
+			RequiredLabels: createLabels,
+			Visibility:     createVisibility,
 		}
 
 		project, err := c.CreateProject(req)
@@ -40,6 +48,10 @@ var projectCreateCmd = &cobra.Command{
 			{"Name", project.Name},
 			{"Source URL", project.SourceURL},
 			{"Docker Image", project.DockerImage},
+			{"Resources", resourcesStr(project.Resources)},
+			{"Build Timeout", timeoutStr(project.BuildTimeout)},
+			{"Labels", labelsStr(project.RequiredLabels)},
+			{"Visibility", project.Visibility},
 		})
 		return nil
 	},
@@ -51,6 +63,10 @@ func init() {
 	projectCreateCmd.Flags().StringVar(&createName, "name", "", "human-readable name")
 	projectCreateCmd.Flags().StringVar(&createSourceURL, "source-url", "", "git repository URL")
 	projectCreateCmd.Flags().StringVar(&createDockerImage, "docker-image", "", "Docker image the builder will run for this project")
+	createResources.register(projectCreateCmd)
+	projectCreateCmd.Flags().IntVar(&createTimeout, "timeout", 0, "build container timeout in seconds (capped by the builder)")
+	projectCreateCmd.Flags().StringSliceVar(&createLabels, "label", nil, "label a builder must advertise to build this project (repeatable)")
+	projectCreateCmd.Flags().StringVar(&createVisibility, "visibility", "public", "who may read the published docs: public, internal, or private")
 	_ = projectCreateCmd.MarkFlagRequired("slug")
 	_ = projectCreateCmd.MarkFlagRequired("name")
 	_ = projectCreateCmd.MarkFlagRequired("source-url")
@@ -0,0 +1,12 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var projectEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage build environment variables and secrets",
+}
+
+func init() {
+	projectCmd.AddCommand(projectEnvCmd)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectEnvListCmd = &cobra.Command{
+	Use:   "list [slug]",
+	Short: "List build variables for a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vars, err := c.ListProjectVariables(args[0])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(vars)
+		}
+		rows := make([][]string, len(vars))
+		for i, v := range vars {
+			value := v.Value
+			if v.Secret {
+				value = "********"
+			}
+			rows[i] = []string{v.Key, orDash(value), boolStr(v.Secret), v.UpdatedAt}
+		}
+		ui.PrintTable([]string{"Key", "Value", "Secret", "Updated"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	projectEnvCmd.AddCommand(projectEnvListCmd)
+}
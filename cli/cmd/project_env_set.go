@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var envSetSecret bool
+
+var projectEnvSetCmd = &cobra.Command{
+	Use:   "set [slug] [key] [value]",
+	Short: "Create or replace a build variable",
+	Long: `Create or replace a build variable.  The variable is injected into the
+builder container of every subsequent build.  Secret values are encrypted on
+the server, never shown again, and masked in stored build logs.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := c.SetProjectVariable(args[0], args[1], client.ProjectVariableSet{
+			Value:  args[2],
+			Secret: envSetSecret,
+		})
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(v)
+		}
+		ui.Success("Variable " + v.Key + " set.")
+		return nil
+	},
+}
+
+func init() {
+	projectEnvCmd.AddCommand(projectEnvSetCmd)
+	projectEnvSetCmd.Flags().BoolVar(&envSetSecret, "secret", false, "encrypt the value and mask it in build logs")
+}
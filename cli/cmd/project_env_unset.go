@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectEnvUnsetCmd = &cobra.Command{
+	Use:   "unset [slug] [key]",
+	Short: "Remove a build variable",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := c.DeleteProjectVariable(args[0], args[1]); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]string{"deleted": args[1]})
+		}
+		ui.Success("Variable " + args[1] + " removed.")
+		return nil
+	},
+}
+
+func init() {
+	projectEnvCmd.AddCommand(projectEnvUnsetCmd)
+}
@@ -25,6 +25,10 @@ var projectGetCmd = &cobra.Command{
 			{"Name", project.Name},
 			{"Source URL", project.SourceURL},
 			{"Docker Image", project.DockerImage},
+			{"Resources", resourcesStr(project.Resources)},
+			{"Build Timeout", timeoutStr(project.BuildTimeout)},
+			{"Labels", labelsStr(project.RequiredLabels)},
+			{"Visibility", project.Visibility},
 			{"Created", project.CreatedAt},
 			{"Updated", project.UpdatedAt},
 		})
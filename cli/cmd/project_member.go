@@ -0,0 +1,16 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var projectMemberCmd = &cobra.Command{
+	Use:   "member",
+	Short: "Manage who may change a project",
+	Long: `Manage project members.  A membership gives a user a role on one project
+on top of their global role: maintainers may change the project, trigger
+builds, and publish versions; admins may also delete it and manage its
+members.  Every signed-in user may read every project.`,
+}
+
+func init() {
+	projectCmd.AddCommand(projectMemberCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var memberAddRole string
+
+var projectMemberAddCmd = &cobra.Command{
+	Use:   "add [slug] [username]",
+	Short: "Add a member to a project, or change their role",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := c.SetMember(args[0], args[1], memberAddRole)
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(m)
+		}
+		ui.Success(m.Username + " is now " + m.Role + " of " + args[0] + ".")
+		return nil
+	},
+}
+
+func init() {
+	projectMemberCmd.AddCommand(projectMemberAddCmd)
+	projectMemberAddCmd.Flags().StringVar(&memberAddRole, "role", "maintainer", "role on the project: viewer, maintainer, or admin")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectMemberListCmd = &cobra.Command{
+	Use:   "list [slug]",
+	Short: "List the members of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		members, err := c.ListMembers(args[0])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(members)
+		}
+		rows := make([][]string, len(members))
+		for i, m := range members {
+			rows[i] = []string{m.Username, m.Role, m.CreatedAt}
+		}
+		ui.PrintTable([]string{"Username", "Role", "Added"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	projectMemberCmd.AddCommand(projectMemberListCmd)
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectMemberRemoveCmd = &cobra.Command{
+	Use:   "remove [slug] [username]",
+	Short: "Remove a member from a project",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := c.RemoveMember(args[0], args[1]); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]string{"removed": args[1]})
+		}
+		ui.Success(args[1] + " removed from " + args[0] + ".")
+		return nil
+	},
+}
+
+func init() {
+	projectMemberCmd.AddCommand(projectMemberRemoveCmd)
+}
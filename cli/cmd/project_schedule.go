@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var projectScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage cron schedules that rebuild a project",
+	Long: `Manage cron schedules that rebuild a project periodically, e.g. to pick up
+external data.  Expressions are standard five-field cron ("0 3 * * *") or a
+macro (@hourly, @daily, @weekly, @monthly), evaluated in UTC.`,
+}
+
+func parseScheduleID(s string) (uint, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule id: %s", s)
+	}
+	return uint(id), nil
+}
+
+func schedulePairs(s client.Schedule) [][]string {
+	pairs := [][]string{
+		{"ID", fmt.Sprint(s.ID)},
+		{"Cron", s.Cron},
+		{"Ref", orDash(s.Ref)},
+		{"Tag", orDash(s.Tag)},
+		{"Enabled", boolStr(s.Enabled)},
+		{"Catch Up", boolStr(s.CatchUp)},
+		{"Next Run", s.NextRunAt},
+	}
+	if s.LastRunAt != "" {
+		pairs = append(pairs, []string{"Last Run", s.LastRunAt})
+	}
+	if s.LastBuildID != 0 {
+		pairs = append(pairs, []string{"Last Build", fmt.Sprint(s.LastBuildID)})
+	}
+	return pairs
+}
+
+func init() {
+	projectCmd.AddCommand(projectScheduleCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleAddRef      string
+	scheduleAddTag      string
+	scheduleAddDisabled bool
+	scheduleAddNoCatch  bool
+)
+
+var projectScheduleAddCmd = &cobra.Command{
+	Use:   "add [slug] [cron]",
+	Short: "Add a build schedule",
+	Long: `Add a build schedule.  Each activation queues a build of --ref (default
+branch when empty) published as --tag (no version when empty).  A run missed
+while the server was down still happens once on startup unless --no-catch-up
+is given.`,
+	Example: `  doc-thor project schedule add my-docs "0 3 * * *" --ref main --tag latest`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enabled, catchUp := !scheduleAddDisabled, !scheduleAddNoCatch
+		s, err := c.CreateSchedule(args[0], client.ScheduleCreate{
+			Cron:    args[1],
+			Ref:     scheduleAddRef,
+			Tag:     scheduleAddTag,
+			Enabled: &enabled,
+			CatchUp: &catchUp,
+		})
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(s)
+		}
+		ui.Success("Schedule created.")
+		ui.DetailCard("Schedule", schedulePairs(s))
+		return nil
+	},
+}
+
+func init() {
+	projectScheduleCmd.AddCommand(projectScheduleAddCmd)
+	projectScheduleAddCmd.Flags().StringVar(&scheduleAddRef, "ref", "", "git ref to build")
+	projectScheduleAddCmd.Flags().StringVar(&scheduleAddTag, "tag", "", "version tag to publish")
+	projectScheduleAddCmd.Flags().BoolVar(&scheduleAddDisabled, "disabled", false, "create the schedule disabled")
+	projectScheduleAddCmd.Flags().BoolVar(&scheduleAddNoCatch, "no-catch-up", false, "skip runs missed while the server was down")
+}
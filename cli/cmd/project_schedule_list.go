@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectScheduleListCmd = &cobra.Command{
+	Use:   "list [slug]",
+	Short: "List build schedules for a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schedules, err := c.ListSchedules(args[0])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(schedules)
+		}
+		rows := make([][]string, len(schedules))
+		for i, s := range schedules {
+			rows[i] = []string{fmt.Sprint(s.ID), s.Cron, orDash(s.Ref), orDash(s.Tag), boolStr(s.Enabled), boolStr(s.CatchUp), s.NextRunAt, orDash(s.LastRunAt)}
+		}
+		ui.PrintTable([]string{"ID", "Cron", "Ref", "Tag", "Enabled", "Catch Up", "Next Run", "Last Run"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	projectScheduleCmd.AddCommand(projectScheduleListCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectScheduleRemoveCmd = &cobra.Command{
+	Use:   "remove [slug] [schedule-id]",
+	Short: "Remove a build schedule",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseScheduleID(args[1])
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteSchedule(args[0], id); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]uint{"deleted": id})
+		}
+		ui.Success("Schedule removed.")
+		return nil
+	},
+}
+
+func init() {
+	projectScheduleCmd.AddCommand(projectScheduleRemoveCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleUpdateCron    string
+	scheduleUpdateRef     string
+	scheduleUpdateTag     string
+	scheduleUpdateEnabled bool
+	scheduleUpdateCatchUp bool
+)
+
+var projectScheduleUpdateCmd = &cobra.Command{
+	Use:   "update [slug] [schedule-id]",
+	Short: "Update a build schedule",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseScheduleID(args[1])
+		if err != nil {
+			return err
+		}
+
+		req := client.ScheduleUpdate{}
+		flags := cmd.Flags()
+		if flags.Changed("cron") {
+			req.Cron = &scheduleUpdateCron
+		}
+		if flags.Changed("ref") {
+			req.Ref = &scheduleUpdateRef
+		}
+		if flags.Changed("tag") {
+			req.Tag = &scheduleUpdateTag
+		}
+		if flags.Changed("enabled") {
+			req.Enabled = &scheduleUpdateEnabled
+		}
+		if flags.Changed("catch-up") {
+			req.CatchUp = &scheduleUpdateCatchUp
+		}
+		if req == (client.ScheduleUpdate{}) {
+			return fmt.Errorf("nothing to update — provide at least one flag")
+		}
+
+		s, err := c.UpdateSchedule(args[0], id, req)
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(s)
+		}
+		ui.Success("Schedule updated.")
+		ui.DetailCard("Schedule", schedulePairs(s))
+		return nil
+	},
+}
+
+func init() {
+	projectScheduleCmd.AddCommand(projectScheduleUpdateCmd)
+	projectScheduleUpdateCmd.Flags().StringVar(&scheduleUpdateCron, "cron", "", "new cron expression")
+	projectScheduleUpdateCmd.Flags().StringVar(&scheduleUpdateRef, "ref", "", "new git ref")
+	projectScheduleUpdateCmd.Flags().StringVar(&scheduleUpdateTag, "tag", "", "new version tag")
+	projectScheduleUpdateCmd.Flags().BoolVar(&scheduleUpdateEnabled, "enabled", true, "enable or disable (--enabled=false) the schedule")
+	projectScheduleUpdateCmd.Flags().BoolVar(&scheduleUpdateCatchUp, "catch-up", true, "run missed activations once on startup")
+}
@@ -12,6 +12,10 @@ var (
 	updateName        string
 	updateSourceURL   string
 	updateDockerImage string
+	updateResources   resourceFlags
+	updateTimeout     int
+	updateLabels      []string
+	updateVisibility  string
 )
 
 var projectUpdateCmd = &cobra.Command{
@@ -34,6 +38,31 @@ var projectUpdateCmd = &cobra.Command{
 			req.DockerImage = updateDockerImage
 			changed = true
 		}
+		// Resource flags replace the whole request server-side, so any flag
+		// left out falls back to the builder default.
+		if r := updateResources.request(cmd); r != nil {
+			req.Resources = r
+			changed = true
+		}
+		if cmd.Flags().Changed("timeout") {
+			req.BuildTimeout = &updateTimeout
+			changed = true
+		}
+		// Labels replace the whole list; --label "" clears it.
+		if cmd.Flags().Changed("label") {
+			labels := []string{}
+			for _, l := range updateLabels {
+				if l != "" {
+					labels = append(labels, l)
+				}
+			}
+			req.RequiredLabels = &labels
+			changed = true
+		}
+		if cmd.Flags().Changed("visibility") {
+			req.Visibility = updateVisibility
+			changed = true
+		}
 
 		if !changed {
 			return fmt.Errorf("nothing to update — provide at least one flag")
@@ -53,6 +82,10 @@ var projectUpdateCmd = &cobra.Command{
 			{"Name", project.Name},
 			{"Source URL", project.SourceURL},
 			{"Docker Image", project.DockerImage},
+			{"Resources", resourcesStr(project.Resources)},
+			{"Build Timeout", timeoutStr(project.BuildTimeout)},
+			{"Labels", labelsStr(project.RequiredLabels)},
+			{"Visibility", project.Visibility},
 		})
 		return nil
 	},
@@ -63,4 +96,8 @@ func init() {
 	projectUpdateCmd.Flags().StringVar(&updateName, "name", "", "new name")
 	projectUpdateCmd.Flags().StringVar(&updateSourceURL, "source-url", "", "new git URL")
 	projectUpdateCmd.Flags().StringVar(&updateDockerImage, "docker-image", "", "new Docker image")
+	updateResources.register(projectUpdateCmd)
+	projectUpdateCmd.Flags().IntVar(&updateTimeout, "timeout", 0, "new build container timeout in seconds (capped by the builder; 0 for the default)")
+	projectUpdateCmd.Flags().StringSliceVar(&updateLabels, "label", nil, "replace the labels a builder must advertise (repeatable; \"\" clears)")
+	projectUpdateCmd.Flags().StringVar(&updateVisibility, "visibility", "", "who may read the published docs: public, internal, or private")
 }
@@ -38,7 +38,7 @@ var serverStatusCmd = &cobra.Command{
 
 		rows := make([][]string, len(backends))
 		for i, b := range backends {
-			rows[i] = []string{b.Name, b.URL, boolStr(b.Healthy), b.LastCheck}
+			rows[i] = []string{b.Name, orDash(b.URL), boolStr(b.Healthy), b.LastCheck}
 		}
 		fmt.Println()
 		ui.PrintTable([]string{"Backend", "URL", "Healthy", "Last Check"}, rows)
@@ -0,0 +1,15 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user accounts (admin only)",
+	Long: `Manage user accounts.  Every user has a global role: viewers may only
+read, maintainers may also create projects and change any project, and
+admins may additionally manage users, integrations, and builders.`,
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+}
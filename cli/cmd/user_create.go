@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	userCreatePassword string
+	userCreateRole     string
+)
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create [username]",
+	Short: "Create a user account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if userCreatePassword == "" {
+			if ui.JSON {
+				return fmt.Errorf("--password is required with --json")
+			}
+			if err := huh.NewInput().
+				Title("Password for " + args[0]).
+				EchoMode(huh.EchoModePassword).
+				Value(&userCreatePassword).
+				Run(); err != nil {
+				return err
+			}
+		}
+
+		user, err := c.CreateUser(client.UserCreate{
+			Username: args[0],
+			Password: userCreatePassword,
+			Role:     userCreateRole,
+		})
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(user)
+		}
+		ui.Success("User " + user.Username + " created as " + user.Role + ".")
+		return nil
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userCreateCmd)
+	userCreateCmd.Flags().StringVar(&userCreatePassword, "password", "", "initial password (prompted if omitted)")
+	userCreateCmd.Flags().StringVar(&userCreateRole, "role", "viewer", "global role: viewer, maintainer, or admin")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/charmbracelet/huh"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var userDeleteCmd = &cobra.Command{
+	Use:   "delete [username]",
+	Short: "Delete a user account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := args[0]
+
+		if !ui.JSON {
+			var ok bool
+			if err := huh.NewConfirm().
+				Title("Delete user " + username + "?").
+				Description("This also revokes their sessions and API keys and removes their project memberships.").
+				Value(&ok).
+				Run(); err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+
+		if err := c.DeleteUser(username); err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]string{"deleted": username})
+		}
+		ui.Success("User " + username + " deleted.")
+		return nil
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userDeleteCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List user accounts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		users, err := c.ListUsers()
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(users)
+		}
+		rows := make([][]string, len(users))
+		for i, u := range users {
+			rows[i] = []string{fmt.Sprint(u.ID), u.Username, u.Role, u.CreatedAt}
+		}
+		ui.PrintTable([]string{"ID", "Username", "Role", "Created"}, rows)
+		return nil
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userListCmd)
+}
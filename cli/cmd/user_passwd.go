@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/romain325/doc-thor/cli/internal/client"
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	passwdCurrent string
+	passwdNew     string
+)
+
+var userPasswdCmd = &cobra.Command{
+	Use:   "passwd [username]",
+	Short: "Change your password, or reset another user's",
+	Long: `Without a username, change your own password; your other sessions are
+signed out.  With a username (admin only), set that user's password and
+sign out all of their sessions.  API keys keep working either way.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		self := len(args) == 0
+
+		if !ui.JSON {
+			var fields []huh.Field
+			if self && passwdCurrent == "" {
+				fields = append(fields, huh.NewInput().
+					Title("Current password").
+					EchoMode(huh.EchoModePassword).
+					Value(&passwdCurrent))
+			}
+			if passwdNew == "" {
+				fields = append(fields, huh.NewInput().
+					Title("New password").
+					EchoMode(huh.EchoModePassword).
+					Value(&passwdNew))
+			}
+			if len(fields) > 0 {
+				if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+					return err
+				}
+			}
+		} else if passwdNew == "" || (self && passwdCurrent == "") {
+			return fmt.Errorf("--new-password (and --current-password for your own) are required with --json")
+		}
+
+		var err error
+		if self {
+			err = c.ChangePassword(client.PasswordChange{CurrentPassword: passwdCurrent, NewPassword: passwdNew})
+		} else {
+			err = c.SetUserPassword(args[0], passwdNew)
+		}
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(map[string]bool{"changed": true})
+		}
+		ui.Success("Password changed.")
+		return nil
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userPasswdCmd)
+	userPasswdCmd.Flags().StringVar(&passwdCurrent, "current-password", "", "your current password")
+	userPasswdCmd.Flags().StringVar(&passwdNew, "new-password", "", "the new password")
+}
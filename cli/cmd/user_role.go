@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var userRoleCmd = &cobra.Command{
+	Use:   "role [username] [viewer|maintainer|admin]",
+	Short: "Change a user's global role",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := c.SetUserRole(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(user)
+		}
+		ui.Success(user.Username + " is now " + user.Role + ".")
+		return nil
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userRoleCmd)
+}
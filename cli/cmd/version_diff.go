@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var versionDiffCmd = &cobra.Command{
+	Use:   "diff [slug] [from] [to]",
+	Short: "Show the pages that changed between two versions",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		diff, err := c.DiffVersions(args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(diff)
+		}
+		if len(diff.Added)+len(diff.Removed)+len(diff.Modified) == 0 {
+			ui.Success(fmt.Sprintf("No page changed between %s and %s.", diff.From, diff.To))
+			return nil
+		}
+
+		for _, p := range diff.Added {
+			fmt.Println(ui.SuccessStyle.Render("added:    " + p))
+		}
+		for _, p := range diff.Removed {
+			fmt.Println(ui.ErrorStyle.Render("removed:  " + p))
+		}
+		for _, p := range diff.Modified {
+			fmt.Println(ui.WarningStyle.Render("modified: " + p.Path))
+		}
+		for _, p := range diff.Modified {
+			if p.Error != "" {
+				fmt.Println(ui.ErrorStyle.Render(p.Path + ": " + p.Error))
+			}
+			if p.Diff != "" {
+				fmt.Println()
+				printDiff(p.Diff)
+			}
+		}
+		if diff.Truncated {
+			fmt.Println(ui.WarningStyle.Render("Too many modified pages: only the first ones were diffed."))
+		}
+		return nil
+	},
+}
+
+// printDiff colours a unified diff line by line.
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "@@"):
+			fmt.Println(ui.KeyStyle.UnsetWidth().Render(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(ui.SuccessStyle.UnsetBold().Render(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(ui.ErrorStyle.UnsetBold().Render(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+func init() {
+	versionCmd.AddCommand(versionDiffCmd)
+}
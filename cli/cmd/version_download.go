@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var downloadOutput string
+
+var versionDownloadCmd = &cobra.Command{
+	Use:   "download [slug] [version]",
+	Short: "Download the archived output of a version",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Download to a temp file first so a failed transfer never leaves a
+		// truncated archive under the final name.
+		dir := "."
+		if downloadOutput != "" {
+			dir = filepath.Dir(downloadOutput)
+		}
+		tmp, err := os.CreateTemp(dir, ".doc-thor-download-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		filename, err := c.DownloadArchive(args[0], args[1], tmp)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		dest := downloadOutput
+		if dest == "" && filename != "" {
+			dest = filepath.Base(filename)
+		}
+		if dest == "" {
+			dest = args[0] + "-" + args[1] + ".tar.gz"
+		}
+		if err := os.Rename(tmp.Name(), dest); err != nil {
+			return err
+		}
+
+		if ui.JSON {
+			return ui.PrintJSON(map[string]string{"file": dest})
+		}
+		ui.Success(fmt.Sprintf("Saved %s %s to %s.", args[0], args[1], dest))
+		return nil
+	},
+}
+
+func init() {
+	versionCmd.AddCommand(versionDownloadCmd)
+	versionDownloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "destination file (default: name suggested by the server)")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/romain325/doc-thor/cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var versionFilesCmd = &cobra.Command{
+	Use:   "files [slug] [version]",
+	Short: "List the files of a version's output",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := c.ListVersionFiles(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if ui.JSON {
+			return ui.PrintJSON(files)
+		}
+		if len(files) == 0 {
+			fmt.Println("No manifest recorded for this version.")
+			return nil
+		}
+		var total int64
+		rows := make([][]string, len(files))
+		for i, f := range files {
+			total += f.Size
+			rows[i] = []string{f.Path, fmt.Sprint(f.Size), shortHash(f.SHA256), f.ContentType}
+		}
+		ui.PrintTable([]string{"Path", "Size", "SHA-256", "Type"}, rows)
+		fmt.Printf("%d files, %d bytes\n", len(files), total)
+		return nil
+	},
+}
+
+// shortHash abbreviates a hex digest for table output.
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+func init() {
+	versionCmd.AddCommand(versionFilesCmd)
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -122,6 +125,31 @@ func (c *Client) Backends() ([]BackendStatus, error) {
 	return v, err
 }
 
+// ---------------------------------------------------------------------------
+// Builders
+// ---------------------------------------------------------------------------
+
+type Builder struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Labels      []string `json:"labels"`
+	LastSeenAt  string   `json:"last_seen_at"`
+	Online      bool     `json:"online"`
+	CurrentJobs []uint   `json:"current_jobs"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+func (c *Client) ListBuilders() ([]Builder, error) {
+	var v []Builder
+	err := c.decode("GET", "/builders", nil, &v)
+	return v, err
+}
+
+func (c *Client) DeleteBuilder(id uint) error {
+	return c.decode("DELETE", fmt.Sprintf("/builders/%d", id), nil, nil)
+}
+
 // ---------------------------------------------------------------------------
 // Auth
 // ---------------------------------------------------------------------------
@@ -142,12 +170,25 @@ func (c *Client) Login(req LoginRequest) (LoginResponse, error) {
 }
 
 type APIKeyCreateRequest struct {
-	Label string `json:"label,omitempty"`
+	Label         string   `json:"label,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	Project       string   `json:"project,omitempty"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+type APIKey struct {
+	ID         uint     `json:"id"`
+	Label      string   `json:"label"`
+	Scopes     []string `json:"scopes"`
+	Project    string   `json:"project"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
 }
 
 type APIKeyResponse struct {
-	Key   string `json:"key"`
-	Label string `json:"label"`
+	APIKey
+	Key string `json:"key"`
 }
 
 func (c *Client) CreateAPIKey(req APIKeyCreateRequest) (APIKeyResponse, error) {
@@ -156,9 +197,20 @@ func (c *Client) CreateAPIKey(req APIKeyCreateRequest) (APIKeyResponse, error) {
 	return v, err
 }
 
+func (c *Client) ListAPIKeys() ([]APIKey, error) {
+	var v []APIKey
+	err := c.decode("GET", "/auth/apikeys", nil, &v)
+	return v, err
+}
+
+func (c *Client) RevokeAPIKey(id uint) error {
+	return c.decode("DELETE", fmt.Sprintf("/auth/apikeys/%d", id), nil, nil)
+}
+
 type User struct {
 	ID        uint   `json:"id"`
 	Username  string `json:"username"`
+	Role      string `json:"role"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
@@ -169,31 +221,157 @@ func (c *Client) Me() (User, error) {
 	return v, err
 }
 
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (c *Client) ChangePassword(req PasswordChange) error {
+	return c.decode("PUT", "/auth/password", req, nil)
+}
+
+func (c *Client) Logout() error {
+	return c.decode("POST", "/auth/logout", nil, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Users
+// ---------------------------------------------------------------------------
+
+type UserCreate struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+}
+
+func (c *Client) CreateUser(req UserCreate) (User, error) {
+	var v User
+	err := c.decode("POST", "/users", req, &v)
+	return v, err
+}
+
+func (c *Client) ListUsers() ([]User, error) {
+	var v []User
+	err := c.decode("GET", "/users", nil, &v)
+	return v, err
+}
+
+func (c *Client) DeleteUser(username string) error {
+	return c.decode("DELETE", "/users/"+username, nil, nil)
+}
+
+func (c *Client) SetUserPassword(username, password string) error {
+	return c.decode("PUT", "/users/"+username+"/password", map[string]string{"password": password}, nil)
+}
+
+type RoleUpdate struct {
+	Role string `json:"role"`
+}
+
+func (c *Client) SetUserRole(username, role string) (User, error) {
+	var v User
+	err := c.decode("PUT", "/users/"+username+"/role", RoleUpdate{Role: role}, &v)
+	return v, err
+}
+
+// ---------------------------------------------------------------------------
+// Audit log
+// ---------------------------------------------------------------------------
+
+type AuditEvent struct {
+	ID        uint   `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Actor     string `json:"actor"`
+	APIKeyID  *uint  `json:"api_key_id,omitempty"`
+	Action    string `json:"action"`
+	Target    string `json:"target"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+	SourceIP  string `json:"source_ip"`
+	Status    int    `json:"status"`
+}
+
+// AuditFilter selects audit events; empty fields match everything.  Since
+// and Until are RFC 3339 times.  Failed keeps only refused and failed
+// attempts.
+type AuditFilter struct {
+	Actor, Action, Target string
+	Since, Until          string
+	Failed                bool
+	Limit, Offset         int
+}
+
+func (c *Client) ListAuditEvents(f AuditFilter) ([]AuditEvent, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"actor": f.Actor, "action": f.Action, "target": f.Target, "since": f.Since, "until": f.Until} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if f.Failed {
+		q.Set("failed", "true")
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		q.Set("offset", strconv.Itoa(f.Offset))
+	}
+	var v []AuditEvent
+	err := c.decode("GET", "/audit?"+q.Encode(), nil, &v)
+	return v, err
+}
+
 // ---------------------------------------------------------------------------
 // Projects
 // ---------------------------------------------------------------------------
 
 type Project struct {
-	ID          uint   `json:"id"`
-	Slug        string `json:"slug"`
-	Name        string `json:"name"`
-	SourceURL   string `json:"source_url"`
-	DockerImage string `json:"docker_image"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          uint            `json:"id"`
+	Slug        string          `json:"slug"`
+	Name        string          `json:"name"`
+	SourceURL   string          `json:"source_url"`
+	DockerImage string          `json:"docker_image"`
+	Resources   *BuildResources `json:"resources,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	// BuildTimeout is in seconds; 0 means the builder default.
+	BuildTimeout int `json:"build_timeout,omitempty"`
+	// RequiredLabels must all be advertised by a builder to claim a build.
+	RequiredLabels []string `json:"required_labels,omitempty"`
+	// Visibility is public, internal (signed-in users) or private (members).
+	Visibility string `json:"visibility"`
+}
+
+// BuildResources is a project's build container resource request.  The
+// builder caps every value at its own configured maxima.
+type BuildResources struct {
+	MemoryMB int64   `json:"memory_mb,omitempty"`
+	CPUs     float64 `json:"cpus,omitempty"`
+	Pids     int64   `json:"pids,omitempty"`
+	Network  *bool   `json:"network,omitempty"`
 }
 
 type ProjectCreate struct {
-	Slug        string `json:"slug"`
-	Name        string `json:"name"`
-	SourceURL   string `json:"source_url"`
-	DockerImage string `json:"docker_image"`
+	Slug           string          `json:"slug"`
+	Name           string          `json:"name"`
+	SourceURL      string          `json:"source_url"`
+	DockerImage    string          `json:"docker_image"`
+	Resources      *BuildResources `json:"resources,omitempty"`
+	BuildTimeout   int             `json:"build_timeout,omitempty"`
+	RequiredLabels []string        `json:"required_labels,omitempty"`
+	Visibility     string          `json:"visibility,omitempty"`
 }
 
 type ProjectUpdate struct {
-	Name        string `json:"name,omitempty"`
-	SourceURL   string `json:"source_url,omitempty"`
-	DockerImage string `json:"docker_image,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	SourceURL    string          `json:"source_url,omitempty"`
+	DockerImage  string          `json:"docker_image,omitempty"`
+	Resources    *BuildResources `json:"resources,omitempty"`
+	BuildTimeout *int            `json:"build_timeout,omitempty"`
+	// RequiredLabels replaces the list when non-nil; an empty list clears it.
+	RequiredLabels *[]string `json:"required_labels,omitempty"`
+	Visibility     string    `json:"visibility,omitempty"`
 }
 
 func (c *Client) ListProjects() ([]Project, error) {
@@ -224,22 +402,156 @@ func (c *Client) DeleteProject(slug string) error {
 	return c.decode("DELETE", "/projects/"+slug, nil, nil)
 }
 
+// ---------------------------------------------------------------------------
+// Project members
+// ---------------------------------------------------------------------------
+
+type ProjectMember struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (c *Client) ListMembers(slug string) ([]ProjectMember, error) {
+	var v []ProjectMember
+	err := c.decode("GET", "/projects/"+slug+"/members", nil, &v)
+	return v, err
+}
+
+func (c *Client) SetMember(slug, username, role string) (ProjectMember, error) {
+	var v ProjectMember
+	err := c.decode("PUT", "/projects/"+slug+"/members/"+username, RoleUpdate{Role: role}, &v)
+	return v, err
+}
+
+func (c *Client) RemoveMember(slug, username string) error {
+	return c.decode("DELETE", "/projects/"+slug+"/members/"+username, nil, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Project variables
+// ---------------------------------------------------------------------------
+
+type ProjectVariable struct {
+	ID        uint   `json:"id"`
+	ProjectID uint   `json:"project_id"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	Secret    bool   `json:"secret"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ProjectVariableSet struct {
+	Value  string `json:"value"`
+	Secret bool   `json:"secret"`
+}
+
+func (c *Client) ListProjectVariables(slug string) ([]ProjectVariable, error) {
+	var v []ProjectVariable
+	err := c.decode("GET", "/projects/"+slug+"/variables", nil, &v)
+	return v, err
+}
+
+func (c *Client) SetProjectVariable(slug, key string, req ProjectVariableSet) (ProjectVariable, error) {
+	var v ProjectVariable
+	err := c.decode("PUT", "/projects/"+slug+"/variables/"+key, req, &v)
+	return v, err
+}
+
+func (c *Client) DeleteProjectVariable(slug, key string) error {
+	return c.decode("DELETE", "/projects/"+slug+"/variables/"+key, nil, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Build schedules
+// ---------------------------------------------------------------------------
+
+type Schedule struct {
+	ID          uint   `json:"id"`
+	ProjectID   uint   `json:"project_id"`
+	Cron        string `json:"cron"`
+	Ref         string `json:"ref"`
+	Tag         string `json:"tag"`
+	Enabled     bool   `json:"enabled"`
+	CatchUp     bool   `json:"catch_up"`
+	NextRunAt   string `json:"next_run_at"`
+	LastRunAt   string `json:"last_run_at,omitempty"`
+	LastBuildID uint   `json:"last_build_id,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type ScheduleCreate struct {
+	Cron    string `json:"cron"`
+	Ref     string `json:"ref,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	CatchUp *bool  `json:"catch_up,omitempty"`
+}
+
+type ScheduleUpdate struct {
+	Cron    *string `json:"cron,omitempty"`
+	Ref     *string `json:"ref,omitempty"`
+	Tag     *string `json:"tag,omitempty"`
+	Enabled *bool   `json:"enabled,omitempty"`
+	CatchUp *bool   `json:"catch_up,omitempty"`
+}
+
+func (c *Client) ListSchedules(slug string) ([]Schedule, error) {
+	var v []Schedule
+	err := c.decode("GET", "/projects/"+slug+"/schedules", nil, &v)
+	return v, err
+}
+
+func (c *Client) CreateSchedule(slug string, req ScheduleCreate) (Schedule, error) {
+	var v Schedule
+	err := c.decode("POST", "/projects/"+slug+"/schedules", req, &v)
+	return v, err
+}
+
+func (c *Client) UpdateSchedule(slug string, id uint, req ScheduleUpdate) (Schedule, error) {
+	var v Schedule
+	err := c.decode("PUT", fmt.Sprintf("/projects/%s/schedules/%d", slug, id), req, &v)
+	return v, err
+}
+
+func (c *Client) DeleteSchedule(slug string, id uint) error {
+	return c.decode("DELETE", fmt.Sprintf("/projects/%s/schedules/%d", slug, id), nil, nil)
+}
+
 // ---------------------------------------------------------------------------
 // Builds
 // ---------------------------------------------------------------------------
 
 type Build struct {
-	ID         uint   `json:"id"`
-	ProjectID  uint   `json:"project_id"`
-	Ref        string `json:"ref"`
-	Tag        string `json:"tag"`
+	ID         uint     `json:"id"`
+	ProjectID  uint     `json:"project_id"`
+	Ref        string   `json:"ref"`
+	Tag        string   `json:"tag"`
+	Commit     string   `json:"commit,omitempty"`
+	Status     string   `json:"status"`
+	Trigger    string   `json:"trigger"`
+	Priority   int      `json:"priority"`
+	Logs       string   `json:"logs,omitempty"` // tail only; see LogSize
+	LogSize    int64    `json:"log_size,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	StartedAt  string   `json:"started_at"`
+	FinishedAt string   `json:"finished_at"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	// Stages is only returned by GetBuild.
+	Stages []BuildStage `json:"stages,omitempty"`
+}
+
+// BuildStage is the outcome of one builder pipeline stage.
+type BuildStage struct {
+	Name       string `json:"name"`
 	Status     string `json:"status"`
-	Logs       string `json:"logs,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
 	Error      string `json:"error,omitempty"`
-	StartedAt  string `json:"started_at"`
-	FinishedAt string `json:"finished_at"`
-	CreatedAt  string `json:"created_at"`
-	UpdatedAt  string `json:"updated_at"`
 }
 
 type BuildCreate struct {
@@ -266,6 +578,21 @@ func (c *Client) GetBuild(slug string, id uint) (Build, error) {
 	return v, err
 }
 
+// LogChunk is a page of a build's full log; keep reading from NextOffset
+// until it reaches Total.
+type LogChunk struct {
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Total      int64  `json:"total"`
+	Content    string `json:"content"`
+}
+
+func (c *Client) GetBuildLogs(slug string, id uint, offset int64, limit int) (LogChunk, error) {
+	var v LogChunk
+	err := c.decode("GET", fmt.Sprintf("/projects/%s/builds/%d/logs?offset=%d&limit=%d", slug, id, offset, limit), nil, &v)
+	return v, err
+}
+
 // ---------------------------------------------------------------------------
 // Versions
 // ---------------------------------------------------------------------------
@@ -277,6 +604,7 @@ type Version struct {
 	Version   string `json:"version"`
 	Published bool   `json:"published"`
 	IsLatest  bool   `json:"is_latest"`
+	Archive   string `json:"archive,omitempty"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
@@ -298,6 +626,60 @@ func (c *Client) UpdateVersion(slug, ver string, req VersionUpdate) (Version, er
 	return v, err
 }
 
+// VersionFile is one entry of a version's output manifest.
+type VersionFile struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+}
+
+func (c *Client) ListVersionFiles(slug, ver string) ([]VersionFile, error) {
+	var f []VersionFile
+	err := c.decode("GET", "/projects/"+slug+"/versions/"+ver+"/files", nil, &f)
+	return f, err
+}
+
+// VersionDiff lists the pages that differ between two versions.
+type VersionDiff struct {
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Added     []string   `json:"added"`
+	Removed   []string   `json:"removed"`
+	Modified  []PageDiff `json:"modified"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// PageDiff is the unified text diff of one modified page.
+type PageDiff struct {
+	Path  string `json:"path"`
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (c *Client) DiffVersions(slug, from, to string) (VersionDiff, error) {
+	var d VersionDiff
+	err := c.decode("GET", "/projects/"+slug+"/versions/"+from+"/diff/"+to, nil, &d)
+	return d, err
+}
+
+// DownloadArchive streams the archive of a version's output into w and
+// returns the filename suggested by the server.
+func (c *Client) DownloadArchive(slug, ver string, w io.Writer) (string, error) {
+	resp, err := c.do("GET", "/projects/"+slug+"/versions/"+ver+"/archive", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	filename := ""
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		filename = params["filename"]
+	}
+	_, err = io.Copy(w, resp.Body)
+	return filename, err
+}
+
 // ---------------------------------------------------------------------------
 // VCS Integrations
 // ---------------------------------------------------------------------------
@@ -26,6 +26,8 @@ BASE_DOMAIN   = os.environ["BASE_DOMAIN"]
 STORAGE_URL    = os.environ["STORAGE_URL"].rstrip("/")
 STORAGE_BUCKET = os.environ["STORAGE_BUCKET"]
 POLL_INTERVAL  = int(os.environ.get("POLL_INTERVAL", "10"))
+# Versions built with STORAGE_BACKEND=local are served from this directory.
+LOCAL_STORAGE_DIR = os.environ.get("LOCAL_STORAGE_DIR", "").rstrip("/")
 
 CONF_DIR      = Path("/etc/nginx/conf.d")
 TEMPLATE_DIR  = Path(__file__).resolve().parent / "templates"
@@ -37,7 +39,7 @@ def _template():
 
 
 def _fetch_projects() -> list[dict]:
-    """GET /projects  →  [{slug, versions[], latest}, ...]"""
+    """GET /projects  →  [{slug, versions[], latest, storage{}, visibility}, ...]"""
     resp = requests.get(
         f"{SERVER_URL}/projects",
         headers={"Authorization": f"Bearer {NGINX_TOKEN}"},
@@ -64,6 +66,8 @@ def sync(template, projects: list[dict]):
             base_domain=BASE_DOMAIN,
             storage_url=STORAGE_URL,
             storage_bucket=STORAGE_BUCKET,
+            local_storage_dir=LOCAL_STORAGE_DIR,
+            server_url=SERVER_URL,
         )
 
         dest = CONF_DIR / fname
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -17,7 +18,11 @@ import (
 
 type contextKey string
 
-const ctxUser contextKey = "user"
+const (
+	ctxUser    contextKey = "user"
+	ctxToken   contextKey = "token"
+	ctxBuilder contextKey = "builder"
+)
 
 var ErrUnauthorized = errors.New("unauthorized")
 
@@ -59,8 +64,16 @@ func UserFromContext(ctx context.Context) *models.User {
 	return u
 }
 
+// TokenFromContext returns the session token or API key the request was
+// authenticated with by RequireAuth, or nil.
+func TokenFromContext(ctx context.Context) *models.Token {
+	t, _ := ctx.Value(ctxToken).(*models.Token)
+	return t
+}
+
 // RequireAuth is a chi-compatible middleware. It extracts a Bearer token,
 // validates it against the DB, and injects the owning User into the context.
+// API keys are held to their scopes and project by RequireKeyScope.
 func RequireAuth(db *gorm.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -69,17 +82,66 @@ func RequireAuth(db *gorm.DB) func(http.Handler) http.Handler {
 				denyJSON(w)
 				return
 			}
-			user, err := validateToken(db, raw)
-			if err != nil {
+			user, token, err := validateToken(db, raw)
+			if err != nil || (token.Type != "session" && token.Type != "apikey") {
 				denyJSON(w)
 				return
 			}
 			ctx := context.WithValue(r.Context(), ctxUser, user)
+			ctx = context.WithValue(ctx, ctxToken, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// BuilderFromContext returns the registered builder injected by
+// RequireBuilder, or nil.
+func BuilderFromContext(ctx context.Context) *models.Builder {
+	b, _ := ctx.Value(ctxBuilder).(*models.Builder)
+	return b
+}
+
+// RequireBuilder guards the builder job endpoints.  Only builder
+// credentials are accepted: user tokens, however privileged, cannot claim
+// builds or report results.  The Builder is injected into the context and
+// its last-seen time refreshed.
+func RequireBuilder(db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := extractBearer(r)
+			if raw == "" {
+				denyJSON(w)
+				return
+			}
+			var b models.Builder
+			if err := db.Where("token_hash = ?", HashToken(raw)).Limit(1).Find(&b).Error; err != nil || b.ID == 0 {
+				denyJSON(w)
+				return
+			}
+			now := time.Now()
+			b.LastSeenAt = &now
+			db.Model(&b).UpdateColumn("last_seen_at", now)
+			ctx := context.WithValue(r.Context(), ctxBuilder, &b)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireSecret admits requests whose Bearer token equals secret, such as
+// builders presenting the registration token.  An empty secret admits none.
+func RequireSecret(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := extractBearer(r)
+			if secret == "" || subtle.ConstantTimeCompare([]byte(raw), []byte(secret)) != 1 {
+				denyJSON(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func extractBearer(r *http.Request) string {
 	h := r.Header.Get("Authorization")
 	if !strings.HasPrefix(h, "Bearer ") {
@@ -88,21 +150,35 @@ func extractBearer(r *http.Request) string {
 	return strings.TrimPrefix(h, "Bearer ")
 }
 
-func validateToken(db *gorm.DB, raw string) (*models.User, error) {
+// DocsUser returns the user a raw docs token belongs to.  Docs tokens only
+// sign in to docs sites, and nothing else is accepted there: the cookie
+// holding one is sent to every docs host.
+func DocsUser(db *gorm.DB, raw string) (*models.User, *models.Token, error) {
+	user, token, err := validateToken(db, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.Type != "docs" {
+		return nil, nil, ErrUnauthorized
+	}
+	return user, token, nil
+}
+
+func validateToken(db *gorm.DB, raw string) (*models.User, *models.Token, error) {
 	hash := HashToken(raw)
 	var token models.Token
 	if err := db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
-		return nil, ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
 		db.Delete(&token)
-		return nil, ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 	var user models.User
 	if err := db.First(&user, token.UserID).Error; err != nil {
-		return nil, ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
-	return &user, nil
+	return &user, &token, nil
 }
 
 func denyJSON(w http.ResponseWriter) {
@@ -110,3 +186,9 @@ func denyJSON(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusUnauthorized)
 	w.Write([]byte(`{"error":"unauthorized"}`)) //nolint:errcheck
 }
+
+func forbidJSON(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(`{"error":"forbidden"}`)) //nolint:errcheck
+}
@@ -52,7 +52,7 @@ func main() {
 	user := models.User{
 		Username:     username,
 		PasswordHash: hash,
-		IsSuperuser:  true,
+		Role:         models.RoleAdmin,
 	}
 	if err := db.Create(&user).Error; err != nil {
 		log.Fatalf("failed to create superuser: %v", err)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/romain325/doc-thor/server/config"
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/secrets"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// rotatekey moves every stored secret from the current master key
+// (SECRET_KEY or SECRET_KEY_FILE) to the one in new-key-file.  Stop the
+// server first, then restart it with the new key.
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <new-key-file>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	cfg := config.Load()
+
+	oldKey, err := secrets.LoadKey(cfg.SecretKey, cfg.SecretKeyFile)
+	if err != nil {
+		log.Fatalf("invalid SECRET_KEY: %v", err)
+	}
+	if oldKey == nil {
+		log.Fatalf("SECRET_KEY or SECRET_KEY_FILE must hold the current key")
+	}
+	newKey, err := secrets.LoadKey("", os.Args[1])
+	if err != nil {
+		log.Fatalf("invalid new key: %v", err)
+	}
+	if err := secrets.SetKey(oldKey); err != nil {
+		log.Fatalf("invalid SECRET_KEY: %v", err)
+	}
+	if err := secrets.SetKey(newKey); err != nil {
+		log.Fatalf("invalid new key: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(cfg.DatabaseURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+
+	db.Exec("PRAGMA journal_mode=WAL")
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&models.VCSIntegration{}, &models.ProjectVariable{}); err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+
+	n, err := services.MigrateSecrets(db)
+	if err != nil {
+		log.Fatalf("failed to rotate secrets: %v", err)
+	}
+	fmt.Printf("%d secrets now use the new key; set SECRET_KEY (or SECRET_KEY_FILE) to it and restart the server\n", n)
+}
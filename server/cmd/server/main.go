@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/romain325/doc-thor/server/auth"
 	"github.com/romain325/doc-thor/server/config"
 	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/oidc"
+	"github.com/romain325/doc-thor/server/ratelimit"
 	"github.com/romain325/doc-thor/server/routes"
+	"github.com/romain325/doc-thor/server/scheduler"
+	"github.com/romain325/doc-thor/server/secrets"
+	"github.com/romain325/doc-thor/server/services"
 	"github.com/romain325/doc-thor/server/vcs"
 	"github.com/romain325/doc-thor/server/vcs/gitlab"
 	"gorm.io/driver/sqlite"
@@ -22,6 +29,21 @@ func main() {
 
 	cfg := config.Load()
 
+	key, err := secrets.LoadKey(cfg.SecretKey, cfg.SecretKeyFile)
+	if err == nil && key != nil {
+		err = secrets.SetKey(key)
+	}
+	if err != nil {
+		log.Fatalf("invalid SECRET_KEY: %v", err)
+	}
+	if key == nil {
+		log.Printf("WARNING: SECRET_KEY not set: VCS integration credentials are stored in plaintext and secret project variables are disabled")
+	}
+
+	if err := routes.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	db, err := gorm.Open(sqlite.Open(cfg.DatabaseURL), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
@@ -35,63 +57,215 @@ func main() {
 	if err := db.AutoMigrate(
 		&models.Project{},
 		&models.Build{},
+		&models.BuildStage{},
 		&models.Version{},
+		&models.VersionFile{},
 		&models.User{},
 		&models.Token{},
 		&models.VCSIntegration{},
+		&models.ProjectVariable{},
+		&models.BuildSchedule{},
+		&models.Builder{},
+		&models.RevokedBuilder{},
+		&models.ProjectMember{},
+		&models.AuditEvent{},
 	); err != nil {
 		log.Fatalf("failed to migrate: %v", err)
 	}
+	if err := services.ProtectAuditLog(db); err != nil {
+		log.Fatalf("failed to protect the audit log: %v", err)
+	}
+	if err := services.BackfillRoles(db); err != nil {
+		log.Fatalf("failed to assign user roles: %v", err)
+	}
+	if secrets.Enabled() {
+		n, err := services.MigrateSecrets(db)
+		if err != nil {
+			log.Fatalf("failed to encrypt stored secrets: %v", err)
+		}
+		if n > 0 {
+			log.Printf("encrypted %d stored secrets with the current SECRET_KEY", n)
+		}
+	}
 
 	seedUser(db, cfg)
 
+	imagePolicy := services.ImagePolicy{
+		AllowedPatterns: cfg.ImageAllowedPatterns,
+		RequireDigest:   cfg.ImageRequireDigest,
+	}
+	logs := services.LogStore{
+		Dir:       cfg.LogDir,
+		MaxBytes:  int64(cfg.LogMaxSizeMB) << 20,
+		TailBytes: cfg.LogTailKB << 10,
+	}
+	storage := services.StorageLocations{
+		Endpoint: cfg.StorageEndpoint,
+		LocalDir: cfg.LocalStorageDir,
+	}
+
+	builderOfflineAfter := time.Duration(cfg.BuilderOfflineAfterSeconds) * time.Second
+	if cfg.BuilderRegistrationToken == "" {
+		log.Printf("BUILDER_REGISTRATION_TOKEN not set: builders cannot register")
+	}
+
+	if cfg.SchedulerIntervalSeconds > 0 {
+		go scheduler.Run(context.Background(), db, time.Duration(cfg.SchedulerIntervalSeconds)*time.Second)
+	} else {
+		log.Printf("SCHEDULER_INTERVAL_SECONDS is 0: build schedules will not run")
+	}
+
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	// --- public ---
 	r.Get("/api/v1/health", routes.Health())
-	r.Post("/api/v1/auth/login", routes.Login(db, cfg.SessionTTLHours))
+	// One guard for both password logins, so failures on the API and on a
+	// docs host count together.
+	loginWindow := time.Duration(cfg.LoginAttemptWindowSeconds) * time.Second
+	loginLockout := time.Duration(cfg.LoginLockoutSeconds) * time.Second
+	loginGuard := routes.LoginGuard{
+		PerIP:   ratelimit.NewLockout(cfg.LoginMaxAttemptsPerIP, loginWindow, loginLockout),
+		PerUser: ratelimit.NewLockout(cfg.LoginMaxAttemptsPerUser, loginWindow, loginLockout),
+	}
+	r.Post("/api/v1/auth/login", routes.Login(db, cfg.SessionTTLHours, cfg.PasswordLogin, loginGuard))
+	var sso *routes.SSOSettings
+	if cfg.OIDCIssuerURL != "" {
+		provider, err := oidc.New(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
+		if err != nil {
+			log.Fatalf("invalid OIDC_ISSUER_URL: %v", err)
+		}
+		sso = &routes.SSOSettings{
+			Provider:      provider,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+			GroupsClaim:   cfg.OIDCGroupsClaim,
+			Roles: services.SSORoleMapping{
+				AdminGroups:      cfg.OIDCAdminGroups,
+				MaintainerGroups: cfg.OIDCMaintainerGroups,
+				ViewerGroups:     cfg.OIDCViewerGroups,
+				DefaultRole:      cfg.OIDCDefaultRole,
+			},
+			SessionTTLHours: cfg.SessionTTLHours,
+		}
+		r.Get("/api/v1/auth/oidc/login", routes.SSOLogin(*sso))
+		r.Get("/api/v1/auth/oidc/callback", routes.SSOCallback(db, *sso))
+	}
+
+	// Access checks and login pages for docs that are not public; nginx
+	// proxies /_doc-thor/ on guarded docs hosts here.
+	docsAuth := routes.DocsAuthSettings{
+		BaseDomain:      cfg.BaseDomain,
+		Scheme:          cfg.DocsScheme,
+		SessionTTLHours: cfg.SessionTTLHours,
+		PasswordLogin:   cfg.PasswordLogin,
+		SSO:             sso,
+		Guard:           loginGuard,
+	}
+	r.Get("/api/v1/docs-auth/check", routes.DocsAuthCheck(db))
+	r.Get("/api/v1/docs-auth/login", routes.DocsLoginPage(docsAuth))
+	r.Post("/api/v1/docs-auth/login", routes.DocsLogin(db, docsAuth))
+	r.Get("/api/v1/docs-auth/sso", routes.DocsSSO(docsAuth))
+	r.Get("/api/v1/docs-auth/session", routes.DocsSession(db, docsAuth))
+	r.Get("/api/v1/docs-auth/logout", routes.DocsLogout(db, docsAuth))
+
+	// Builder registration (authenticated by the shared registration token)
+	r.With(auth.RequireSecret(cfg.BuilderRegistrationToken)).Post("/api/v1/builders/register", routes.RegisterBuilder(db, cfg.BuilderRegistrationToken, builderOfflineAfter))
+
+	// Builder job endpoints
+	r.Group(func(r chi.Router) {
+		r.Use(auth.RequireBuilder(db))
+		r.Get("/api/v1/builds/pending", routes.ClaimPendingBuild(db, logs, cfg.MaxRunningPerProject, cfg.BaseDomain, cfg.DocsScheme))
+		r.Post("/api/v1/builds/{id}/result", routes.ReportBuildResult(db, logs))
+	})
 
 	// Webhooks (public - called by VCS platforms)
-	routes.RegisterWebhookRoutes(r, db)
+	routes.RegisterWebhookRoutes(r, db, ratelimit.NewLimiter(cfg.WebhookRatePerMinute, cfg.WebhookBurst))
 
 	// --- authenticated ---
+	// Every signed-in user may read.  Changes need a role: globally for
+	// creating projects and for system settings, on the project otherwise
+	// (global role or membership, see routes.RequireProjectRole).  Every
+	// change, and every refused attempt at one, lands in the audit log.
 	r.Group(func(r chi.Router) {
 		r.Use(auth.RequireAuth(db))
+		r.Use(routes.Audit(db))
+		r.Use(auth.RequireKeyScope(db))
+		admin := routes.RequireRole(models.RoleAdmin)
+		projectMaintainer := routes.RequireProjectRole(db, models.RoleMaintainer)
+		projectAdmin := routes.RequireProjectRole(db, models.RoleAdmin)
+		docsReader := routes.RequireDocsAccess(db)
 
 		// Projects
-		r.Post("/api/v1/projects", routes.CreateProject(db))
+		r.With(routes.RequireRole(models.RoleMaintainer)).Post("/api/v1/projects", routes.CreateProject(db, imagePolicy))
 		r.Get("/api/v1/projects", routes.ListProjects(db))
 		r.Get("/api/v1/projects/{slug}", routes.GetProject(db))
-		r.Put("/api/v1/projects/{slug}", routes.UpdateProject(db))
-		r.Delete("/api/v1/projects/{slug}", routes.DeleteProject(db))
+		r.With(projectMaintainer).Put("/api/v1/projects/{slug}", routes.UpdateProject(db, imagePolicy, cfg.NginxConfigDir, storage, cfg.NginxAuthUpstream))
+		r.With(projectAdmin).Delete("/api/v1/projects/{slug}", routes.DeleteProject(db, logs))
+
+		// Project members
+		r.Get("/api/v1/projects/{slug}/members", routes.ListMembers(db))
+		r.With(projectAdmin).Put("/api/v1/projects/{slug}/members/{username}", routes.SetMember(db))
+		r.With(projectAdmin).Delete("/api/v1/projects/{slug}/members/{username}", routes.RemoveMember(db))
+
+		// Project build variables
+		r.With(projectMaintainer).Get("/api/v1/projects/{slug}/variables", routes.ListProjectVariables(db))
+		r.With(projectMaintainer).Put("/api/v1/projects/{slug}/variables/{key}", routes.SetProjectVariable(db))
+		r.With(projectMaintainer).Delete("/api/v1/projects/{slug}/variables/{key}", routes.DeleteProjectVariable(db))
+
+		// Build schedules
+		r.Get("/api/v1/projects/{slug}/schedules", routes.ListSchedules(db))
+		r.With(projectMaintainer).Post("/api/v1/projects/{slug}/schedules", routes.CreateSchedule(db))
+		r.With(projectMaintainer).Put("/api/v1/projects/{slug}/schedules/{id}", routes.UpdateSchedule(db))
+		r.With(projectMaintainer).Delete("/api/v1/projects/{slug}/schedules/{id}", routes.DeleteSchedule(db))
 
 		// Builds
-		r.Post("/api/v1/projects/{slug}/builds", routes.CreateBuild(db))
+		r.With(projectMaintainer).Post("/api/v1/projects/{slug}/builds", routes.CreateBuild(db))
 		r.Get("/api/v1/projects/{slug}/builds", routes.ListBuilds(db))
 		r.Get("/api/v1/projects/{slug}/builds/{id}", routes.GetBuild(db))
-
-		// Builder job endpoints
-		r.Get("/api/v1/builds/pending", routes.ClaimPendingBuild(db))
-		r.Post("/api/v1/builds/{id}/result", routes.ReportBuildResult(db))
+		r.Get("/api/v1/projects/{slug}/builds/{id}/logs", routes.GetBuildLogs(db, logs))
 
 		// Versions
 		r.Get("/api/v1/projects/{slug}/versions", routes.ListVersions(db))
-		r.Put("/api/v1/projects/{slug}/versions/{ver}", routes.UpdateVersion(db, cfg.NginxConfigDir, cfg.StorageEndpoint))
+		r.With(projectMaintainer).Put("/api/v1/projects/{slug}/versions/{ver}", routes.UpdateVersion(db, cfg.NginxConfigDir, storage, cfg.NginxAuthUpstream))
+		r.With(docsReader).Get("/api/v1/projects/{slug}/versions/{ver}/archive", routes.DownloadVersionArchive(db, storage))
+		r.With(docsReader).Get("/api/v1/projects/{slug}/versions/{ver}/files", routes.ListVersionFiles(db))
+		r.With(docsReader).Get("/api/v1/projects/{slug}/versions/{ver}/diff/{to}", routes.DiffVersions(db, storage))
 
 		// Auth (key management + introspection)
 		r.Post("/api/v1/auth/apikey", routes.CreateAPIKey(db))
+		r.Get("/api/v1/auth/apikeys", routes.ListAPIKeys(db))
+		r.Delete("/api/v1/auth/apikeys/{id}", routes.RevokeAPIKey(db))
 		r.Get("/api/v1/auth/me", routes.GetMe(db))
+		r.Put("/api/v1/auth/password", routes.ChangePassword(db))
+		r.Post("/api/v1/auth/logout", routes.Logout(db))
+
+		// Users
+		r.With(admin).Post("/api/v1/users", routes.CreateUser(db))
+		r.With(admin).Get("/api/v1/users", routes.ListUsers(db))
+		r.With(admin).Delete("/api/v1/users/{username}", routes.DeleteUser(db))
+		r.With(admin).Put("/api/v1/users/{username}/password", routes.SetUserPassword(db))
+		r.With(admin).Put("/api/v1/users/{username}/role", routes.SetUserRole(db))
+
+		// Builders
+		r.Get("/api/v1/builders", routes.ListBuilders(db, builderOfflineAfter))
+		r.With(admin).Delete("/api/v1/builders/{id}", routes.DeleteBuilder(db, cfg.BuilderRegistrationToken))
 
 		// System
-		r.Get("/api/v1/backends", routes.Backends(cfg.BuilderEndpoints, cfg.StorageEndpoint, cfg.StorageUseSSL))
+		r.Get("/api/v1/backends", routes.Backends(db, builderOfflineAfter, cfg.StorageEndpoint, cfg.StorageUseSSL))
+		r.With(admin).Get("/api/v1/audit", routes.ListAuditEvents(db))
 
 		// VCS Integrations
 		routes.RegisterVCSIntegrationRoutes(r, db)
 
 		// Project Discovery
-		routes.RegisterDiscoveryRoutes(r, db)
+		routes.RegisterDiscoveryRoutes(r, db, imagePolicy)
 	})
 
 	log.Printf("doc-thor server listening on :%s", cfg.Port)
@@ -115,7 +289,7 @@ func seedUser(db *gorm.DB, cfg config.Config) {
 	if err != nil {
 		log.Fatalf("failed to hash initial password: %v", err)
 	}
-	user := models.User{Username: cfg.InitialUser, PasswordHash: hash}
+	user := models.User{Username: cfg.InitialUser, PasswordHash: hash, Role: models.RoleAdmin}
 	if err := db.Create(&user).Error; err != nil {
 		log.Fatalf("failed to create initial user: %v", err)
 	}
@@ -14,10 +14,76 @@ type Config struct {
 	StorageAccessKey string
 	StorageSecretKey string
 	StorageUseSSL    bool
-	BuilderEndpoints []string
-	SessionTTLHours  int
-	InitialUser      string
-	InitialPassword  string
+	// LocalStorageDir is where builders using the local storage backend
+	// write output.  nginx and the server must mount it at this path.
+	LocalStorageDir string
+	SessionTTLHours int
+	InitialUser     string
+	InitialPassword string
+	// BuilderRegistrationToken is the shared secret builders present to
+	// register and obtain their own credential; empty disables registration.
+	// A builder not heard from for BuilderOfflineAfterSeconds shows offline.
+	BuilderRegistrationToken   string
+	BuilderOfflineAfterSeconds int
+	// BaseDomain and DocsScheme describe where published docs are served:
+	// <DocsScheme>://<slug>-<version>.<BaseDomain>/.  Builders receive the
+	// resulting URL as DOCTHOR_BASE_URL.
+	BaseDomain string
+	DocsScheme string
+	// ImageAllowedPatterns restricts the builder images projects may use
+	// (see services.ImagePolicy); empty allows any image.
+	ImageAllowedPatterns []string
+	ImageRequireDigest   bool
+	// LogDir holds the full output of each build; the database keeps only
+	// the last LogTailKB.  LogMaxSizeMB caps a stored log (0: unlimited).
+	LogDir       string
+	LogMaxSizeMB int
+	LogTailKB    int
+	// MaxRunningPerProject caps how many builds of one project run at once
+	// (0: no cap), so one busy project cannot take every builder.
+	MaxRunningPerProject int
+	// SchedulerIntervalSeconds is how often build schedules are checked;
+	// 0 disables the scheduler (e.g. on all but one of several replicas).
+	SchedulerIntervalSeconds int
+	// SecretKey is the base64-encoded 32-byte master key used to encrypt
+	// secret project variables and VCS integration credentials, given
+	// inline or, with SecretKeyFile, as a file.  Without one, neither can be
+	// stored.
+	SecretKey     string
+	SecretKeyFile string
+	// PasswordLogin allows signing in with a local username and password.
+	// Turn it off when everyone signs in through OIDC.
+	PasswordLogin bool
+	// OIDC single sign-on; disabled while OIDCIssuerURL is empty.  Roles
+	// come from the groups in OIDCGroupsClaim (see services.SSORoleMapping).
+	OIDCIssuerURL        string
+	OIDCClientID         string
+	OIDCClientSecret     string
+	OIDCRedirectURL      string
+	OIDCScopes           []string
+	OIDCUsernameClaim    string
+	OIDCGroupsClaim      string
+	OIDCAdminGroups      []string
+	OIDCMaintainerGroups []string
+	OIDCViewerGroups     []string
+	OIDCDefaultRole      string
+	// NginxAuthUpstream is how nginx reaches this server to check access to
+	// docs that are not public.
+	NginxAuthUpstream string
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Real-IP header names the client (see routes.SetTrustedProxies).
+	TrustedProxies []string
+	// Password sign-ins: an address, or an account, that fails
+	// LoginMaxAttemptsPer* times within LoginAttemptWindowSeconds is locked
+	// out for LoginLockoutSeconds (0 attempts: no limit).
+	LoginMaxAttemptsPerIP     int
+	LoginMaxAttemptsPerUser   int
+	LoginAttemptWindowSeconds int
+	LoginLockoutSeconds       int
+	// WebhookRatePerMinute caps the webhook deliveries each project accepts,
+	// in bursts of up to WebhookBurst (0: no limit).
+	WebhookRatePerMinute int
+	WebhookBurst         int
 }
 
 func Load() Config {
@@ -29,10 +95,51 @@ func Load() Config {
 		StorageAccessKey: getEnv("STORAGE_ACCESS_KEY", ""),
 		StorageSecretKey: getEnv("STORAGE_SECRET_KEY", ""),
 		StorageUseSSL:    getEnvBool("STORAGE_USE_SSL", false),
-		BuilderEndpoints: getEnvList("BUILDER_ENDPOINTS"),
+		LocalStorageDir:  getEnv("LOCAL_STORAGE_DIR", ""),
 		SessionTTLHours:  getEnvInt("SESSION_TTL_HOURS", 24),
 		InitialUser:      getEnv("INITIAL_USER", ""),
 		InitialPassword:  getEnv("INITIAL_PASSWORD", ""),
+		BaseDomain:       getEnv("BASE_DOMAIN", "docs.localhost"),
+		DocsScheme:       getEnv("DOCS_SCHEME", "http"),
+		SecretKey:        getEnv("SECRET_KEY", ""),
+		SecretKeyFile:    getEnv("SECRET_KEY_FILE", ""),
+
+		ImageAllowedPatterns: getEnvList("IMAGE_ALLOWED_PATTERNS"),
+		ImageRequireDigest:   getEnvBool("IMAGE_REQUIRE_DIGEST", false),
+
+		LogDir:       getEnv("LOG_DIR", "./logs"),
+		LogMaxSizeMB: getEnvInt("LOG_MAX_SIZE_MB", 20),
+		LogTailKB:    getEnvInt("LOG_TAIL_KB", 64),
+
+		SchedulerIntervalSeconds: getEnvInt("SCHEDULER_INTERVAL_SECONDS", 30),
+		MaxRunningPerProject:     getEnvInt("MAX_RUNNING_PER_PROJECT", 2),
+
+		BuilderRegistrationToken:   getEnv("BUILDER_REGISTRATION_TOKEN", ""),
+		BuilderOfflineAfterSeconds: getEnvInt("BUILDER_OFFLINE_AFTER_SECONDS", 60),
+
+		PasswordLogin:        getEnvBool("PASSWORD_LOGIN_ENABLED", true),
+		OIDCIssuerURL:        getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:         getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:     getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:      getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:           getEnvList("OIDC_SCOPES"),
+		OIDCUsernameClaim:    getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCGroupsClaim:      getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCAdminGroups:      getEnvList("OIDC_ADMIN_GROUPS"),
+		OIDCMaintainerGroups: getEnvList("OIDC_MAINTAINER_GROUPS"),
+		OIDCViewerGroups:     getEnvList("OIDC_VIEWER_GROUPS"),
+		OIDCDefaultRole:      getEnv("OIDC_DEFAULT_ROLE", "viewer"),
+
+		NginxAuthUpstream: getEnv("NGINX_AUTH_UPSTREAM", "http://server:8080"),
+		TrustedProxies:    getEnvList("TRUSTED_PROXIES"),
+
+		LoginMaxAttemptsPerIP:     getEnvInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		LoginMaxAttemptsPerUser:   getEnvInt("LOGIN_MAX_ATTEMPTS_PER_USER", 5),
+		LoginAttemptWindowSeconds: getEnvInt("LOGIN_ATTEMPT_WINDOW_SECONDS", 900),
+		LoginLockoutSeconds:       getEnvInt("LOGIN_LOCKOUT_SECONDS", 900),
+
+		WebhookRatePerMinute: getEnvInt("WEBHOOK_RATE_PER_MINUTE", 60),
+		WebhookBurst:         getEnvInt("WEBHOOK_BURST", 20),
 	}
 }
 
@@ -0,0 +1,120 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"1,,2 * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): want error", spec)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	bits := func(vs ...int) uint64 {
+		var b uint64
+		for _, v := range vs {
+			b |= 1 << v
+		}
+		return b
+	}
+	tests := []struct {
+		spec   string
+		minute uint64
+		hour   uint64
+		dow    uint64
+	}{
+		{"0 0 * * *", bits(0), bits(0), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"1,15,30 * * * *", bits(1, 15, 30), bits(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"*/20 8-18/5 * * *", bits(0, 20, 40), bits(8, 13, 18), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"50/5 0 * * *", bits(50, 55), bits(0), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"0 0 * * 1-5", bits(0), bits(0), bits(1, 2, 3, 4, 5)},
+		{"0 0 * * mon-FRI", bits(0), bits(0), bits(1, 2, 3, 4, 5)},
+		{"0 0 * * 7", bits(0), bits(0), bits(0, 7)},
+		{"59 23 * * sun,sat", bits(59), bits(23), bits(0, 6)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if s.minute != tt.minute || s.hour != tt.hour || s.dow != tt.dow {
+			t.Errorf("Parse(%q): minute %b hour %b dow %b, want %b %b %b",
+				tt.spec, s.minute, s.hour, s.dow, tt.minute, tt.hour, tt.dow)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2026-01-15 is a Thursday.
+	from := time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2026, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * feb *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sat", time.Date(2026, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week both restricted: either one fires.
+		// The 20th is a Tuesday; Monday the 19th comes first.
+		{"0 0 20 * mon", time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * mon", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		// Only one restricted: it alone decides.
+		{"0 0 20 * *", time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 ? * mon", time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		// Never fires.
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestNextIsStrictlyAfter(t *testing.T) {
+	s, err := Parse("0 12 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got, want := s.Next(at), at.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", at, got, want)
+	}
+}
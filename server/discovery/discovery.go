@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"time"
+
+	"github.com/romain325/doc-thor/server/models"
 )
 
 // BackendStatus is the health-check result for a single backend service.
@@ -14,22 +16,13 @@ type BackendStatus struct {
 	LastCheck time.Time `json:"last_check"`
 }
 
-// CheckBuilder pings a builder's /health endpoint.
-func CheckBuilder(url string) BackendStatus {
-	s := BackendStatus{Name: "builder", URL: url, LastCheck: time.Now()}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/health", nil)
-	if err != nil {
-		return s
+// BuilderStatus describes a registered builder.  Builders are not pinged:
+// they poll the server, so one is healthy while it keeps doing so.
+func BuilderStatus(b models.Builder) BackendStatus {
+	s := BackendStatus{Name: "builder/" + b.Name, Healthy: b.Online}
+	if b.LastSeenAt != nil {
+		s.LastCheck = *b.LastSeenAt
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return s
-	}
-	resp.Body.Close()
-	s.Healthy = resp.StatusCode == http.StatusOK
 	return s
 }
 
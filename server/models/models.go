@@ -13,11 +13,38 @@ type Base struct {
 // Project is a registered documentation source.
 type Project struct {
 	Base
-	Slug        string      `gorm:"uniqueIndex;not null" json:"slug"`
-	Name        string      `gorm:"not null" json:"name"`
-	SourceURL   string      `gorm:"column:source_url;not null" json:"source_url"`
-	DockerImage string      `gorm:"column:docker_image;not null" json:"docker_image"`
-	VCSConfig   *VCSConfig  `gorm:"serializer:json" json:"vcs_config,omitempty"`
+	Slug        string          `gorm:"uniqueIndex;not null" json:"slug"`
+	Name        string          `gorm:"not null" json:"name"`
+	SourceURL   string          `gorm:"column:source_url;not null" json:"source_url"`
+	DockerImage string          `gorm:"column:docker_image;not null" json:"docker_image"`
+	VCSConfig   *VCSConfig      `gorm:"serializer:json" json:"vcs_config,omitempty"`
+	Resources   *BuildResources `gorm:"serializer:json" json:"resources,omitempty"`
+	// BuildTimeout is the requested container timeout in seconds; 0 uses the
+	// builder default.  Builders cap it at their own configured maximum.
+	BuildTimeout int `gorm:"column:build_timeout;not null;default:0" json:"build_timeout,omitempty"`
+	// RequiredLabels are labels a builder must advertise to be handed this
+	// project's builds, e.g. "large" or "gpu".  Empty: any builder.
+	RequiredLabels []string `gorm:"serializer:json" json:"required_labels,omitempty"`
+	// Visibility controls who may read the published docs (Visibility*).
+	Visibility string `gorm:"not null;default:public" json:"visibility"`
+}
+
+// Project visibilities: who may read a project's published docs.
+const (
+	VisibilityPublic   = "public"   // anyone
+	VisibilityInternal = "internal" // any signed-in user
+	VisibilityPrivate  = "private"  // project members, maintainers and admins
+)
+
+// BuildResources is a project's request for build container resources.
+// Zero/nil fields fall back to the builder's defaults, and every value is
+// capped by the builder's configured maxima, so these are requests rather
+// than guarantees.
+type BuildResources struct {
+	MemoryMB int64   `json:"memory_mb,omitempty"`
+	CPUs     float64 `json:"cpus,omitempty"`
+	Pids     int64   `json:"pids,omitempty"`
+	Network  *bool   `json:"network,omitempty"` // nil = builder default
 }
 
 // VCSConfig stores VCS integration settings for a project.
@@ -42,22 +69,71 @@ type VCSIntegration struct {
 	Name          string `gorm:"uniqueIndex;not null" json:"name"` // Unique identifier (e.g., "company-gitlab")
 	Provider      string `gorm:"not null" json:"provider"` // "gitlab" | "github" | "gitea"
 	InstanceURL   string `gorm:"not null" json:"instance_url"` // Base URL of VCS instance
-	AccessToken   string `gorm:"not null" json:"-"` // API token (encrypted at rest)
-	WebhookSecret string `gorm:"not null" json:"-"` // For webhook signature validation
+	AccessToken   string `gorm:"serializer:secret;not null" json:"-"` // API token (encrypted at rest)
+	WebhookSecret string `gorm:"serializer:secret;not null" json:"-"` // For webhook signature validation (encrypted at rest)
 	Enabled       bool   `gorm:"default:true" json:"enabled"`
 }
 
+// ProjectVariable is an environment variable injected into the builder
+// container for every build of a project.  Secret values are encrypted at
+// rest and never returned by the API.
+type ProjectVariable struct {
+	Base
+	ProjectID uint   `gorm:"not null;uniqueIndex:idx_project_variable" json:"project_id"`
+	Key       string `gorm:"not null;uniqueIndex:idx_project_variable" json:"key"`
+	Value     string `gorm:"type:text" json:"value,omitempty"` // ciphertext when Secret is true
+	Secret    bool   `gorm:"default:false" json:"secret"`
+}
+
 // Build tracks a single doc-build job. Status lifecycle: pending → running → success | failed.
 type Build struct {
 	Base
 	ProjectID  uint       `gorm:"not null;index" json:"project_id"`
 	Ref        string     `json:"ref"`
 	Tag        string     `json:"tag"`
+	Commit     string     `json:"commit,omitempty"` // Known up front for webhook builds only
 	Status     string     `gorm:"default:pending" json:"status"`
-	Logs       string     `gorm:"type:text" json:"logs,omitempty"`
+	Trigger    string     `gorm:"not null;default:manual" json:"trigger"`       // manual | webhook | schedule
+	Priority   int        `gorm:"not null;default:0" json:"priority"`           // Derived from Trigger; higher is claimed first
+	Logs       string     `gorm:"type:text" json:"logs,omitempty"`              // Tail of the log; see LogSize
+	LogSize    int64      `gorm:"not null;default:0" json:"log_size,omitempty"` // Size of the full log in the log store
 	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	Warnings   []string   `gorm:"serializer:json" json:"warnings,omitempty"` // Non-fatal output validation findings
 	StartedAt  *time.Time `json:"started_at,omitempty"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	BuilderID  *uint      `gorm:"index" json:"builder_id,omitempty"` // Registered builder that claimed the build
+	// Stages is the per-stage outcome reported by the builder, in pipeline
+	// order.  Only loaded by GetBuild.
+	Stages []BuildStage `gorm:"foreignKey:BuildID" json:"stages,omitempty"`
+}
+
+// BuildStage is the outcome of one builder pipeline stage (pull, run, ...).
+// Status is "success", "failed", or "skipped"; skipped stages have no times.
+type BuildStage struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"-"`
+	BuildID    uint       `gorm:"not null;index" json:"-"`
+	Position   int        `gorm:"not null" json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	Status     string     `gorm:"not null" json:"status"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+}
+
+// BuildSchedule queues a build of a project on a cron schedule (UTC).  When
+// the server was down or busy past NextRunAt, CatchUp decides whether the
+// missed run still happens (once, however many were missed) or is skipped.
+type BuildSchedule struct {
+	Base
+	ProjectID   uint       `gorm:"not null;index" json:"project_id"`
+	Cron        string     `gorm:"not null" json:"cron"`
+	Ref         string     `json:"ref"`
+	Tag         string     `json:"tag"`
+	Enabled     bool       `gorm:"not null" json:"enabled"`
+	CatchUp     bool       `gorm:"not null" json:"catch_up"`
+	NextRunAt   time.Time  `gorm:"index" json:"next_run_at"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastBuildID *uint      `json:"last_build_id,omitempty"`
 }
 
 // Version is a published build output addressable by tag.
@@ -68,23 +144,118 @@ type Version struct {
 	Tag       string `gorm:"not null;uniqueIndex:idx_project_version" json:"version"`
 	Published bool   `gorm:"default:false" json:"published"`
 	IsLatest  bool   `gorm:"default:false;column:is_latest" json:"is_latest"`
+	// Storage is the builder storage backend holding the files ("s3" or
+	// "local"); nginx routing depends on it.
+	Storage string `gorm:"not null;default:s3" json:"storage"`
+	// Archive is the storage key of the downloadable archive of the output;
+	// empty when the builder made none.
+	Archive string `json:"archive,omitempty"`
 }
 
+// VersionFile is one entry of a version's output manifest, as reported by the
+// builder that produced it.  Path is relative to the site root.
+type VersionFile struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	VersionID   uint   `gorm:"not null;index" json:"-"`
+	Path        string `gorm:"not null" json:"path"`
+	Size        int64  `json:"size"`
+	SHA256      string `gorm:"column:sha256" json:"sha256"`
+	ContentType string `json:"content_type"`
+}
+
+// Roles, from least to most privileged.  A user's global role applies to
+// every project; a ProjectMember can raise it on one project.
+const (
+	RoleViewer     = "viewer"
+	RoleMaintainer = "maintainer"
+	RoleAdmin      = "admin"
+)
+
 // User is a local account.
 type User struct {
 	Base
 	Username     string `gorm:"uniqueIndex;not null" json:"username"`
 	PasswordHash string `gorm:"not null" json:"-"`
-	IsSuperuser  bool   `gorm:"default:false" json:"is_superuser"`
+	Role         string `gorm:"index" json:"role"`
+	// OIDCSubject links the account to its single sign-on identity.
+	OIDCSubject string `gorm:"column:oidc_subject;index" json:"-"`
 }
 
+// ProjectMember grants a user a role on one project.  Username is filled in
+// when members are listed.
+type ProjectMember struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	ProjectID uint      `gorm:"not null;uniqueIndex:idx_project_member" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_project_member;index" json:"-"`
+	Username  string    `gorm:"->;-:migration" json:"username"`
+	Role      string    `gorm:"not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// API key scopes.  A key with scopes may read whatever its user can, and
+// change only what its scopes allow; a key without scopes acts as its user.
+const (
+	ScopeBuildsTrigger   = "builds:trigger"   // trigger builds
+	ScopeVersionsPublish = "versions:publish" // publish, unpublish, set latest
+	ScopeProjectsWrite   = "projects:write"   // project settings, variables, schedules
+)
+
 // Token covers both session tokens and API keys.
 // The raw token is never stored; only its SHA-256 hash.
 type Token struct {
 	Base
-	UserID    uint       `gorm:"not null;index"`
-	TokenHash string     `gorm:"uniqueIndex;not null"`
-	Type      string     `gorm:"not null"` // "session" | "apikey"
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	Type      string     `gorm:"not null" json:"type"` // "session" | "apikey" | "docs" | "docs_code"
 	Label     string     `json:"label,omitempty"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// API keys only.  ProjectID restricts the key to one project; Project
+	// is its slug, filled in when keys are listed.
+	Scopes     []string   `gorm:"serializer:json" json:"scopes,omitempty"`
+	ProjectID  *uint      `json:"-"`
+	Project    string     `gorm:"-" json:"project,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Builder is a registered build agent.  Builders register themselves with
+// the server's registration token and receive a credential of their own;
+// like user tokens, only its SHA-256 hash is stored.
+type Builder struct {
+	Base
+	Name       string     `gorm:"uniqueIndex;not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Version    string     `json:"version"`
+	Labels     []string   `gorm:"serializer:json" json:"labels,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	// Online and CurrentJobs are derived when builders are listed.
+	Online      bool   `gorm:"-" json:"online"`
+	CurrentJobs []uint `gorm:"-" json:"current_jobs"`
+}
+
+// RevokedBuilder is the name of a removed builder.  Registering under it is
+// refused while the registration token it was removed under is still the
+// server's, so the removed agent cannot simply register again.
+type RevokedBuilder struct {
+	Name             string `gorm:"primaryKey"`
+	RegistrationHash string `gorm:"not null"`
+	CreatedAt        time.Time
+}
+
+// AuditEvent records one change made through the API: who made it, to
+// what, and from where.  The table is append-only (see
+// services.ProtectAuditLog).
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	Actor     string    `gorm:"not null;index" json:"actor"`                   // username, or "builder:<name>"
+	APIKeyID  *uint     `gorm:"column:api_key_id" json:"api_key_id,omitempty"` // set when acting through an API key
+	Action    string    `gorm:"not null;index" json:"action"`                  // e.g. "version.update"
+	Target    string    `gorm:"index" json:"target"`                           // resource path, e.g. "projects/docs/versions/1.2.0"
+	// Before and After are JSON summaries of the fields that changed.
+	Before   string `gorm:"type:text" json:"before,omitempty"`
+	After    string `gorm:"type:text" json:"after,omitempty"`
+	SourceIP string `json:"source_ip"`
+	// Status is the HTTP status the request got: 400 and up for refused or
+	// failed attempts, which change nothing.
+	Status int `gorm:"not null;default:200;index" json:"status"`
 }
@@ -0,0 +1,11 @@
+package models
+
+import (
+	"github.com/romain325/doc-thor/server/secrets"
+	"gorm.io/gorm/schema"
+)
+
+// Fields tagged serializer:secret are encrypted with the server's master key.
+func init() {
+	schema.RegisterSerializer("secret", secrets.Serializer{})
+}
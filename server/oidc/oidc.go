@@ -0,0 +1,366 @@
+// Package oidc signs users in through an OpenID Connect provider with the
+// authorization-code flow.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginTTL bounds how long a user may take at the provider's login page.
+const LoginTTL = 10 * time.Minute
+
+// maxPending caps the logins waiting for the provider.  Starting one needs
+// no credentials, so past the cap the oldest is dropped rather than let
+// anyone grow the map without bound.
+const maxPending = 10000
+
+var (
+	ErrUnknownState = errors.New("unknown or expired login state")
+	ErrInvalidToken = errors.New("invalid id token")
+	ErrInsecureURL  = errors.New("provider URL must use https (http only on localhost)")
+)
+
+// Config describes the provider and this server's registration with it.
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is this server's callback, registered with the provider.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Provider runs logins against one OIDC provider.  Its metadata is fetched
+// on first use, so the server starts even while the provider is down.
+type Provider struct {
+	cfg  Config
+	http *http.Client
+
+	// fetchMu serializes metadata fetches; mu guards the rest and is never
+	// held across a request to the provider.
+	fetchMu sync.Mutex
+	mu      sync.Mutex
+	meta    *metadata
+	pending map[string]pendingLogin // by state
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	returnTo string
+	expires  time.Time
+}
+
+// New returns a Provider for cfg.  Scopes default to openid, profile and
+// email; openid is always requested.
+//
+// The issuer, and the endpoints it advertises, must use https unless they
+// are on localhost: Finish trusts the ID token because it comes over that
+// connection.
+func New(cfg Config) (*Provider, error) {
+	if err := checkURL(cfg.IssuerURL); err != nil {
+		return nil, fmt.Errorf("issuer: %w", err)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	} else if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	return &Provider{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		pending: map[string]pendingLogin{},
+	}, nil
+}
+
+// checkURL accepts https URLs, and http ones on the loopback interface.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme == "https" && u.Host != "":
+		return nil
+	case u.Scheme == "http":
+		host := u.Hostname()
+		if host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrInsecureURL, raw)
+}
+
+// AuthURL starts a login and returns the provider URL to send the browser
+// to.  returnTo is handed back by Finish once the provider redirects back.
+func (p *Provider) AuthURL(ctx context.Context, returnTo string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	p.mu.Lock()
+	now := time.Now()
+	var oldest string
+	for s, l := range p.pending {
+		if now.After(l.expires) {
+			delete(p.pending, s)
+		} else if oldest == "" || l.expires.Before(p.pending[oldest].expires) {
+			oldest = s
+		}
+	}
+	if len(p.pending) >= maxPending {
+		delete(p.pending, oldest)
+	}
+	p.pending[state] = pendingLogin{nonce: nonce, verifier: verifier, returnTo: returnTo, expires: now.Add(LoginTTL)}
+	p.mu.Unlock()
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// ReturnTo consumes the login started with state without completing it, for
+// when the provider reports an error instead of a code.
+func (p *Provider) ReturnTo(state string) (string, bool) {
+	l, ok := p.take(state)
+	return l.returnTo, ok
+}
+
+// Finish completes the login started with state: it redeems code at the
+// provider and returns the ID token's claims and AuthURL's returnTo.
+//
+// The ID token comes straight from the token endpoint over a TLS
+// connection this server opened (see New), so, as OIDC Core 3.1.3.7 allows,
+// its signature is not checked; its issuer, audience, expiry and nonce are.
+func (p *Provider) Finish(ctx context.Context, state, code string) (Claims, string, error) {
+	l, ok := p.take(state)
+	if !ok {
+		return nil, "", ErrUnknownState
+	}
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, l.returnTo, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {l.verifier},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, l.returnTo, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, l.returnTo, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, l.returnTo, fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, l.returnTo, fmt.Errorf("token request: %s: %s %s", resp.Status, tok.Error, tok.ErrorDescription)
+	}
+
+	claims, err := parseIDToken(tok.IDToken)
+	if err != nil {
+		return nil, l.returnTo, err
+	}
+	if err := claims.validate(meta.Issuer, p.cfg.ClientID, l.nonce, time.Now()); err != nil {
+		return nil, l.returnTo, err
+	}
+	return claims, l.returnTo, nil
+}
+
+func (p *Provider) take(state string) (pendingLogin, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.pending[state]
+	delete(p.pending, state)
+	if !ok || time.Now().After(l.expires) {
+		return pendingLogin{}, false
+	}
+	return l, true
+}
+
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	if m := p.cachedMetadata(); m != nil {
+		return m, nil
+	}
+	p.fetchMu.Lock()
+	defer p.fetchMu.Unlock()
+	if m := p.cachedMetadata(); m != nil {
+		return m, nil
+	}
+
+	u := strings.TrimRight(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: %s", resp.Status)
+	}
+	var m metadata
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	// The issuer tokens are checked against comes from this document, so it
+	// must be the one configured: otherwise whoever serves the document picks
+	// whose tokens are accepted.
+	if m.Issuer == "" || strings.TrimRight(m.Issuer, "/") != strings.TrimRight(p.cfg.IssuerURL, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", m.Issuer, p.cfg.IssuerURL)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" {
+		return nil, errors.New("oidc discovery: missing endpoints")
+	}
+	for _, e := range []string{m.AuthorizationEndpoint, m.TokenEndpoint} {
+		if err := checkURL(e); err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+	}
+	p.mu.Lock()
+	p.meta = &m
+	p.mu.Unlock()
+	return &m, nil
+}
+
+func (p *Provider) cachedMetadata() *metadata {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.meta
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Claims are the claims of an ID token.
+type Claims map[string]any
+
+func parseIDToken(raw string) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return c, nil
+}
+
+func (c Claims) validate(issuer, clientID, nonce string, now time.Time) error {
+	if issuer == "" || c.String("iss") != issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, c.String("iss"))
+	}
+	if !slices.Contains(c.Strings("aud"), clientID) {
+		return fmt.Errorf("%w: audience", ErrInvalidToken)
+	}
+	exp, _ := c["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if c.String("nonce") != nonce {
+		return fmt.Errorf("%w: nonce", ErrInvalidToken)
+	}
+	if c.String("sub") == "" {
+		return fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return nil
+}
+
+// lookup resolves a claim name; dots reach into nested objects, as in
+// Keycloak's "realm_access.roles".
+func (c Claims) lookup(name string) any {
+	var v any = map[string]any(c)
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// String returns a string claim, or "".
+func (c Claims) String(name string) string {
+	s, _ := c.lookup(name).(string)
+	return s
+}
+
+// Strings returns a claim holding a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c.lookup(name).(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
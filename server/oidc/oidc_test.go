@@ -0,0 +1,277 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// mockIssuer is a minimal OIDC provider: discovery and a token endpoint
+// that answers with an unsigned ID token built from claims.
+type mockIssuer struct {
+	*httptest.Server
+	// issuer is what discovery advertises; it defaults to the server's URL.
+	issuer func() string
+	// claims returns the ID token claims for a login with nonce.
+	claims func(nonce string) map[string]any
+	// nonces and challenges hold the nonce and PKCE challenge sent for
+	// each code.
+	nonces, challenges map[string]string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	m := &mockIssuer{nonces: map[string]string{}, challenges: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
+			"issuer":                 m.issuer(),
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.PostFormValue("code")
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if m.challenges[code] != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"}) //nolint:errcheck
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": unsignedToken(m.claims(m.nonces[code]))}) //nolint:errcheck
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	m.issuer = func() string { return m.URL }
+	m.claims = func(nonce string) map[string]any {
+		return map[string]any{
+			"iss":   m.URL,
+			"aud":   "doc-thor",
+			"sub":   "user-1",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": nonce,
+		}
+	}
+	return m
+}
+
+// authorize plays the browser at the provider's login page: it records the
+// login behind authURL under code and returns its state.
+func (m *mockIssuer) authorize(t *testing.T, authURL, code string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q", q.Get("code_challenge_method"))
+	}
+	m.nonces[code], m.challenges[code] = q.Get("nonce"), q.Get("code_challenge")
+	return q.Get("state")
+}
+
+func unsignedToken(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func newProvider(t *testing.T, issuer string) *Provider {
+	p, err := New(Config{IssuerURL: issuer, ClientID: "doc-thor", RedirectURL: "http://localhost/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLogin(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(t, m.URL)
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "http://localhost:9999/done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := m.authorize(t, authURL, "code-1")
+
+	claims, returnTo, err := p.Finish(ctx, state, "code-1")
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if claims.String("sub") != "user-1" || returnTo != "http://localhost:9999/done" {
+		t.Errorf("got sub %q, returnTo %q", claims.String("sub"), returnTo)
+	}
+
+	// A state is good for one login only.
+	if _, _, err := p.Finish(ctx, state, "code-1"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("reused state: err = %v, want ErrUnknownState", err)
+	}
+	if _, _, err := p.Finish(ctx, "forged", "code-1"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("unknown state: err = %v, want ErrUnknownState", err)
+	}
+}
+
+func TestLoginRejectsBadTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c map[string]any)
+	}{
+		{"wrong nonce", func(c map[string]any) { c["nonce"] = "other" }},
+		{"no nonce", func(c map[string]any) { delete(c, "nonce") }},
+		{"wrong audience", func(c map[string]any) { c["aud"] = "someone-else" }},
+		{"audience list without us", func(c map[string]any) { c["aud"] = []string{"a", "b"} }},
+		{"expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }},
+		{"no subject", func(c map[string]any) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			base := m.claims
+			m.claims = func(nonce string) map[string]any {
+				c := base(nonce)
+				tt.modify(c)
+				return c
+			}
+			p := newProvider(t, m.URL)
+			authURL, err := p.AuthURL(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := m.authorize(t, authURL, "code")
+			if _, _, err := p.Finish(context.Background(), state, "code"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMustMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		issuer func(m *mockIssuer) string
+		ok     bool
+	}{
+		{"same", func(m *mockIssuer) string { return m.URL }, true},
+		{"trailing slash", func(m *mockIssuer) string { return m.URL + "/" }, true},
+		{"empty", func(m *mockIssuer) string { return "" }, false},
+		{"other issuer", func(m *mockIssuer) string { return "https://evil.example.com" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			m.issuer = func() string { return tt.issuer(m) }
+			p := newProvider(t, m.URL)
+			_, err := p.AuthURL(context.Background(), "")
+			if tt.ok && err != nil {
+				t.Errorf("AuthURL: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("AuthURL accepted the discovery document")
+			}
+		})
+	}
+}
+
+func TestValidateNeedsIssuer(t *testing.T) {
+	c := Claims{"iss": "", "aud": "doc-thor", "sub": "user-1", "nonce": "n", "exp": float64(time.Now().Add(time.Hour).Unix())}
+	if err := c.validate("", "doc-thor", "n", time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("empty issuer: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoginAcceptsAudienceList(t *testing.T) {
+	m := newMockIssuer(t)
+	base := m.claims
+	m.claims = func(nonce string) map[string]any {
+		c := base(nonce)
+		c["aud"] = []string{"other", "doc-thor"}
+		return c
+	}
+	p := newProvider(t, m.URL)
+	authURL, err := p.AuthURL(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := m.authorize(t, authURL, "code")
+	if _, _, err := p.Finish(context.Background(), state, "code"); err != nil {
+		t.Errorf("Finish: %v", err)
+	}
+}
+
+func TestExpiredLoginState(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(t, m.URL)
+	authURL, err := p.AuthURL(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := m.authorize(t, authURL, "code")
+
+	p.mu.Lock()
+	l := p.pending[state]
+	l.expires = time.Now().Add(-time.Second)
+	p.pending[state] = l
+	p.mu.Unlock()
+
+	if _, _, err := p.Finish(context.Background(), state, "code"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("err = %v, want ErrUnknownState", err)
+	}
+}
+
+func TestInsecureURLs(t *testing.T) {
+	for _, issuer := range []string{"http://idp.example.com", "ftp://idp.example.com", "https://"} {
+		if _, err := New(Config{IssuerURL: issuer}); !errors.Is(err, ErrInsecureURL) {
+			t.Errorf("New(%q): err = %v, want ErrInsecureURL", issuer, err)
+		}
+	}
+	for _, issuer := range []string{"https://idp.example.com", "http://localhost:8081", "http://127.0.0.1:8081"} {
+		if _, err := New(Config{IssuerURL: issuer}); err != nil {
+			t.Errorf("New(%q): %v", issuer, err)
+		}
+	}
+
+	// A local issuer advertising a plain http token endpoint elsewhere.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
+			"issuer":                 "http://" + r.Host,
+			"authorization_endpoint": "http://" + r.Host + "/authorize",
+			"token_endpoint":         "http://idp.example.com/token",
+		})
+	}))
+	defer srv.Close()
+	p := newProvider(t, srv.URL)
+	if _, err := p.AuthURL(context.Background(), ""); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("AuthURL: err = %v, want ErrInsecureURL", err)
+	}
+}
+
+func TestPendingLoginsAreCapped(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newProvider(t, m.URL)
+	ctx := context.Background()
+
+	first, err := p.AuthURL(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstState := m.authorize(t, first, "code")
+	for i := 0; i < maxPending; i++ {
+		if _, err := p.AuthURL(ctx, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(p.pending); n != maxPending {
+		t.Errorf("%d pending logins, want %d", n, maxPending)
+	}
+	if _, _, err := p.Finish(ctx, firstState, "code"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("oldest login: err = %v, want ErrUnknownState", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package ratelimit throttles abusive clients with in-memory counters: a
+// lockout after repeated failures (password guessing) and token buckets
+// (request floods).  State is per process and starts empty on restart.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Lockout locks a key (an address, an account) out for Duration once it
+// has failed Max times within Window.  A nil Lockout, or one with Max 0,
+// never locks anything out.
+type Lockout struct {
+	max      int
+	window   time.Duration
+	duration time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*lockoutEntry
+	lastSweep time.Time
+}
+
+type lockoutEntry struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout returns a Lockout, or nil when max is not positive.
+func NewLockout(max int, window, duration time.Duration) *Lockout {
+	if max <= 0 {
+		return nil
+	}
+	return &Lockout{max: max, window: window, duration: duration, now: time.Now, entries: map[string]*lockoutEntry{}}
+}
+
+// Check reports whether key may try again and, if not, how long it has to
+// wait.
+func (l *Lockout) Check(key string) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		if wait := e.lockedUntil.Sub(l.now()); wait > 0 {
+			return wait, false
+		}
+	}
+	return 0, true
+}
+
+// Fail counts a failure against key and returns how long it is now locked
+// out for, or 0.
+func (l *Lockout) Fail(key string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.windowStart) > l.window {
+		e = &lockoutEntry{windowStart: now}
+		l.entries[key] = e
+	}
+	e.failures++
+	if e.failures < l.max {
+		return 0
+	}
+	// Locked: the count starts over once the lockout ends.
+	e.failures, e.windowStart = 0, now
+	e.lockedUntil = now.Add(l.duration)
+	return l.duration
+}
+
+// Reset forgets the failures of key, as after a successful attempt.
+func (l *Lockout) Reset(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// sweep drops entries with nothing left to remember, at most once per
+// window.  The caller holds mu.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for k, e := range l.entries {
+		if now.Sub(e.windowStart) > l.window && now.After(e.lockedUntil) {
+			delete(l.entries, k)
+		}
+	}
+}
+
+// Limiter allows each key PerMinute requests a minute on average, in
+// bursts of up to Burst.  A nil Limiter allows everything.
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter, or nil when perMinute is not positive.  A
+// burst below 1 is raised to 1.
+func NewLimiter(perMinute, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{rate: float64(perMinute) / 60, burst: math.Max(1, float64(burst)), now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from key's bucket.  When the bucket is empty it
+// returns false and how long until the next token.
+func (l *Limiter) Allow(key string) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep drops buckets that have refilled, at most once a minute.  The
+// caller holds mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock the test moves by hand.
+type fakeClock struct{ t time.Time }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// step is one call in a scenario: advance the clock by after, then call
+// the method on key and compare.
+type step struct {
+	after    time.Duration
+	op       string // "check", "fail", "reset" for Lockout; "allow" for Limiter
+	key      string
+	wantOK   bool
+	wantWait time.Duration
+}
+
+func TestLockout(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"under the limit", []step{
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "check", key: "a", wantOK: true},
+		}},
+		{"locked at the limit", []step{
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a", wantWait: 10 * time.Minute},
+			{op: "check", key: "a", wantWait: 10 * time.Minute},
+			{after: 4 * time.Minute, op: "check", key: "a", wantWait: 6 * time.Minute},
+			{op: "check", key: "b", wantOK: true},
+		}},
+		{"lockout ends", []step{
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a", wantWait: 10 * time.Minute},
+			{after: 10 * time.Minute, op: "check", key: "a", wantOK: true},
+			// The count started over when the lockout began.
+			{op: "fail", key: "a"},
+			{op: "check", key: "a", wantOK: true},
+		}},
+		{"failures outside the window are forgotten", []step{
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{after: 5*time.Minute + time.Second, op: "fail", key: "a"},
+			{op: "check", key: "a", wantOK: true},
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a", wantWait: 10 * time.Minute},
+		}},
+		{"reset clears failures", []step{
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "reset", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "fail", key: "a"},
+			{op: "check", key: "a", wantOK: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := NewLockout(3, 5*time.Minute, 10*time.Minute)
+			l.now = clock.Now
+			for i, s := range tt.steps {
+				clock.Advance(s.after)
+				switch s.op {
+				case "check":
+					wait, ok := l.Check(s.key)
+					if ok != s.wantOK || wait != s.wantWait {
+						t.Errorf("step %d: Check(%q) = %v, %v; want %v, %v", i, s.key, wait, ok, s.wantWait, s.wantOK)
+					}
+				case "fail":
+					if wait := l.Fail(s.key); wait != s.wantWait {
+						t.Errorf("step %d: Fail(%q) = %v, want %v", i, s.key, wait, s.wantWait)
+					}
+				case "reset":
+					l.Reset(s.key)
+				}
+			}
+		})
+	}
+}
+
+func TestLockoutSweep(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLockout(2, time.Minute, time.Hour)
+	l.now = clock.Now
+	l.Fail("locked")
+	l.Fail("locked")
+	for i := 0; i < 100; i++ {
+		l.Fail(fmt.Sprint("k", i))
+	}
+	clock.Advance(2 * time.Minute)
+	l.Fail("new")
+	if n := len(l.entries); n != 2 {
+		t.Errorf("%d entries after sweep, want 2 (the lockout and the new failure)", n)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name             string
+		perMinute, burst int
+		steps            []step
+	}{
+		{"burst then refuse", 60, 3, []step{
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantWait: time.Second},
+			{op: "allow", key: "b", wantOK: true},
+		}},
+		{"refills at the rate", 60, 2, []step{
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantOK: true},
+			{after: 500 * time.Millisecond, op: "allow", key: "a", wantWait: 500 * time.Millisecond},
+			{after: 500 * time.Millisecond, op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantWait: time.Second},
+		}},
+		{"never above the burst", 60, 2, []step{
+			{after: time.Hour, op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantWait: time.Second},
+		}},
+		{"burst below one is one", 6, 0, []step{
+			{op: "allow", key: "a", wantOK: true},
+			{op: "allow", key: "a", wantWait: 10 * time.Second},
+			{after: 10 * time.Second, op: "allow", key: "a", wantOK: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := NewLimiter(tt.perMinute, tt.burst)
+			l.now = clock.Now
+			for i, s := range tt.steps {
+				clock.Advance(s.after)
+				wait, ok := l.Allow(s.key)
+				if ok != s.wantOK || wait != s.wantWait {
+					t.Errorf("step %d: Allow(%q) = %v, %v; want %v, %v", i, s.key, wait, ok, s.wantWait, s.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(60, 10)
+	l.now = clock.Now
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprint("k", i))
+	}
+	clock.Advance(time.Minute)
+	l.Allow("new")
+	if n := len(l.buckets); n != 1 {
+		t.Errorf("%d buckets after sweep, want 1", n)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	if l := NewLockout(0, time.Minute, time.Minute); l != nil {
+		t.Error("NewLockout(0, ...) is not nil")
+	}
+	if l := NewLimiter(0, 10); l != nil {
+		t.Error("NewLimiter(0, ...) is not nil")
+	}
+	var lo *Lockout
+	lo.Fail("a")
+	lo.Reset("a")
+	if _, ok := lo.Check("a"); !ok {
+		t.Error("nil Lockout refused")
+	}
+	var li *Limiter
+	if _, ok := li.Allow("a"); !ok {
+		t.Error("nil Limiter refused")
+	}
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// RequireRole admits users whose global role is at least role.  It must run
+// after auth.RequireAuth.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.UserFromContext(r.Context())
+			if user == nil || !services.RoleAtLeast(user.Role, role) {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireProjectRole admits users holding at least role on the project named
+// by the {slug} URL parameter, through their global role or a membership.
+// It must run after auth.RequireAuth.
+func RequireProjectRole(db *gorm.DB, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.UserFromContext(r.Context())
+			if user == nil {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			project, err := services.GetProject(db, chi.URLParam(r, "slug"))
+			if err != nil {
+				if errors.Is(err, services.ErrNotFound) {
+					writeError(w, http.StatusNotFound, "project not found")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			have, err := services.ProjectRole(db, user, project.ID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			if !services.RoleAtLeast(have, role) {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireDocsAccess admits users who may read the published docs of the
+// project named by the {slug} URL parameter (see services.CanReadDocs).
+// It must run after auth.RequireAuth.
+func RequireDocsAccess(db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			project, err := services.GetProject(db, chi.URLParam(r, "slug"))
+			if err != nil {
+				if errors.Is(err, services.ErrNotFound) {
+					writeError(w, http.StatusNotFound, "project not found")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			if !services.CanReadDocs(db, auth.UserFromContext(r.Context()), project) {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// auditActions names the action of each mutating route, by method and
+// route pattern.  A mutating route missing here is recorded as
+// "<METHOD> <pattern>"; one mapped to "" changes nothing and is not
+// recorded.
+var auditActions = map[string]string{
+	"POST /api/v1/projects":                             "project.create",
+	"PUT /api/v1/projects/{slug}":                       "project.update",
+	"DELETE /api/v1/projects/{slug}":                    "project.delete",
+	"POST /api/v1/projects/import":                      "project.import",
+	"PUT /api/v1/projects/{slug}/members/{username}":    "member.set",
+	"DELETE /api/v1/projects/{slug}/members/{username}": "member.remove",
+	"PUT /api/v1/projects/{slug}/variables/{key}":       "variable.set",
+	"DELETE /api/v1/projects/{slug}/variables/{key}":    "variable.delete",
+	"POST /api/v1/projects/{slug}/schedules":            "schedule.create",
+	"PUT /api/v1/projects/{slug}/schedules/{id}":        "schedule.update",
+	"DELETE /api/v1/projects/{slug}/schedules/{id}":     "schedule.delete",
+	"POST /api/v1/projects/{slug}/builds":               "build.create",
+	"PUT /api/v1/projects/{slug}/versions/{ver}":        "version.update",
+	"POST /api/v1/auth/apikey":                          "apikey.create",
+	"DELETE /api/v1/auth/apikeys/{id}":                  "apikey.revoke",
+	"PUT /api/v1/auth/password":                         "user.password",
+	"POST /api/v1/auth/logout":                          "auth.logout",
+	"POST /api/v1/users":                                "user.create",
+	"DELETE /api/v1/users/{username}":                   "user.delete",
+	"PUT /api/v1/users/{username}/password":             "user.password",
+	"PUT /api/v1/users/{username}/role":                 "user.role",
+	"DELETE /api/v1/builders/{id}":                      "builder.delete",
+	"POST /api/v1/integrations":                         "integration.create",
+	"PUT /api/v1/integrations/{name}":                   "integration.update",
+	"DELETE /api/v1/integrations/{name}":                "integration.delete",
+	"POST /api/v1/integrations/{name}/test":             "",
+	"POST /api/v1/integrations/{name}/discover":         "",
+}
+
+type auditKey struct{}
+
+// auditNote is what a handler adds to the audit event of its request.
+type auditNote struct {
+	target        string
+	before, after any
+}
+
+// Audit records an audit event for every mutating request made by a
+// signed-in user, with the status it got: refusals and failures are kept
+// too.  It must run after auth.RequireAuth.  Handlers fill in what changed
+// with auditChange and, when the URL does not name the resource,
+// auditTarget.
+func Audit(db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			note := &auditNote{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auditKey{}, note)))
+
+			route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+			action, ok := auditActions[route]
+			if !ok {
+				action = route
+			} else if action == "" {
+				return
+			}
+			user := auth.UserFromContext(r.Context())
+			if user == nil {
+				return
+			}
+			e := &models.AuditEvent{
+				Actor:    user.Username,
+				Action:   action,
+				Target:   note.target,
+				SourceIP: clientIP(r),
+				Status:   ww.Status(),
+			}
+			if e.Status == 0 {
+				e.Status = http.StatusOK
+			}
+			if e.Target == "" {
+				e.Target = strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+			}
+			if t := auth.TokenFromContext(r.Context()); t != nil && t.Type == "apikey" {
+				e.APIKeyID = &t.ID
+			}
+			if e.Status < http.StatusBadRequest {
+				e.Before, e.After = auditSummaries(note.before, note.after)
+			}
+			recordAudit(db, e)
+		})
+	}
+}
+
+// auditChange notes the state of the resource r changed, before and after
+// the change; nil for the side that does not exist.  Only fields that
+// differ are kept.
+func auditChange(r *http.Request, before, after any) {
+	if note, ok := r.Context().Value(auditKey{}).(*auditNote); ok {
+		note.before, note.after = before, after
+	}
+}
+
+// auditTarget names the resource r changed, for when its URL does not (as
+// with creations).
+func auditTarget(r *http.Request, target string) {
+	if note, ok := r.Context().Value(auditKey{}).(*auditNote); ok {
+		note.target = target
+	}
+}
+
+// auditEvent records an event outside the Audit middleware, for sign-ins
+// and builders.  A failed sign-in names whoever the caller claimed to be,
+// so actor and target are cut to a sane length.
+func auditEvent(db *gorm.DB, r *http.Request, actor, action, target string, status int) {
+	recordAudit(db, &models.AuditEvent{
+		Actor:    truncate(actor, maxAuditName),
+		Action:   action,
+		Target:   truncate(target, maxAuditName),
+		SourceIP: clientIP(r),
+		Status:   status,
+	})
+}
+
+const maxAuditName = 128
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// recordAudit stores e.  A failure is logged rather than returned: the
+// change it describes has already been made.
+func recordAudit(db *gorm.DB, e *models.AuditEvent) {
+	if err := services.RecordAuditEvent(db, e); err != nil {
+		log.Printf("audit: %s %s by %s not recorded: %v", e.Action, e.Target, e.Actor, err)
+	}
+}
+
+// auditSummaries renders before and after as JSON objects.  When both are
+// given, only the fields whose values differ are kept; timestamps the
+// change itself moves are left out.
+func auditSummaries(before, after any) (string, string) {
+	b, a := auditFields(before), auditFields(after)
+	delete(b, "updated_at")
+	delete(a, "updated_at")
+	if b != nil && a != nil {
+		for k, v := range b {
+			if reflect.DeepEqual(v, a[k]) {
+				delete(b, k)
+				delete(a, k)
+			}
+		}
+	}
+	return auditJSON(b), auditJSON(a)
+}
+
+func auditFields(v any) map[string]any {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+func auditJSON(m map[string]any) string {
+	if len(m) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(m)
+	return string(data)
+}
+
+// ListAuditEvents returns audit events, newest first, filtered by actor,
+// action, target and time (since, until: RFC 3339).
+func ListAuditEvents(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := services.AuditFilter{
+			Actor:  q.Get("actor"),
+			Action: q.Get("action"),
+			Target: strings.Trim(q.Get("target"), "/"),
+			Failed: q.Get("failed") == "true",
+			Limit:  100,
+		}
+		for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+			if v := q.Get(name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, name+" must be an RFC 3339 time")
+					return
+				}
+				*dst = t
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if l, err := strconv.Atoi(v); err == nil && l > 0 {
+				f.Limit = min(l, 1000)
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if o, err := strconv.Atoi(v); err == nil && o >= 0 {
+				f.Offset = o
+			}
+		}
+
+		events, err := services.ListAuditEvents(db, f)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	}
+}
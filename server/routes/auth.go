@@ -2,16 +2,25 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/romain325/doc-thor/server/auth"
 	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/services"
 	"gorm.io/gorm"
 )
 
-func Login(db *gorm.DB, sessionTTLHours int) http.HandlerFunc {
+func Login(db *gorm.DB, sessionTTLHours int, enabled bool, guard LoginGuard) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			writeError(w, http.StatusForbidden, "password login is disabled; sign in with sso")
+			return
+		}
 		var req struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
@@ -20,14 +29,70 @@ func Login(db *gorm.DB, sessionTTLHours int) http.HandlerFunc {
 			writeError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
+		ip := clientIP(r)
+		if wait, ok := guard.check(ip, req.Username); !ok {
+			writeRateLimited(w, wait, "too many failed sign-ins; try again later")
+			return
+		}
 
 		var user models.User
-		if err := db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		if err := db.Where("username = ?", req.Username).First(&user).Error; err != nil ||
+			auth.CheckPassword(req.Password, user.PasswordHash) != nil {
+			guard.fail(ip, req.Username)
+			auditEvent(db, r, req.Username, "auth.login", "users/"+req.Username, http.StatusUnauthorized)
 			writeError(w, http.StatusUnauthorized, "invalid credentials")
 			return
 		}
-		if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
-			writeError(w, http.StatusUnauthorized, "invalid credentials")
+		guard.succeed(req.Username)
+
+		raw, err := issueSession(db, user.ID, sessionTTLHours)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "session creation failed")
+			return
+		}
+		auditEvent(db, r, user.Username, "auth.login", "users/"+user.Username, http.StatusOK)
+
+		writeJSON(w, http.StatusOK, map[string]string{"token": raw})
+	}
+}
+
+// issueSession creates a session token for a user and returns its raw value.
+func issueSession(db *gorm.DB, userID uint, ttlHours int) (string, error) {
+	return issueToken(db, userID, "session", "", time.Duration(ttlHours)*time.Hour)
+}
+
+// issueToken creates a token of the given type that expires after ttl and
+// returns its raw value.
+func issueToken(db *gorm.DB, userID uint, tokenType, label string, ttl time.Duration) (string, error) {
+	raw, hash, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	exp := time.Now().Add(ttl)
+	token := models.Token{
+		UserID:    userID,
+		TokenHash: hash,
+		Type:      tokenType,
+		Label:     label,
+		ExpiresAt: &exp,
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func CreateAPIKey(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		var req services.APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
 
@@ -37,23 +102,77 @@ func Login(db *gorm.DB, sessionTTLHours int) http.HandlerFunc {
 			return
 		}
 
-		exp := time.Now().Add(time.Duration(sessionTTLHours) * time.Hour)
-		token := models.Token{
-			UserID:    user.ID,
-			TokenHash: hash,
-			Type:      "session",
-			ExpiresAt: &exp,
+		token, err := services.CreateAPIKey(db, user.ID, req, hash)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidScope) || errors.Is(err, services.ErrInvalidExpiry) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "project not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+
+		auditTarget(r, fmt.Sprintf("auth/apikeys/%d", token.ID))
+		auditChange(r, nil, token)
+		writeJSON(w, http.StatusCreated, apiKeyCreated{Key: raw, Token: token})
+	}
+}
+
+// apiKeyCreated is the only response that carries the raw key.
+type apiKeyCreated struct {
+	Key string `json:"key"`
+	*models.Token
+}
+
+// ListAPIKeys returns the caller's API keys, without their values.
+func ListAPIKeys(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
 		}
-		if err := db.Create(&token).Error; err != nil {
+		keys, err := services.ListAPIKeys(db, user.ID)
+		if err != nil {
 			writeError(w, http.StatusInternalServerError, "database error")
 			return
 		}
+		writeJSON(w, http.StatusOK, keys)
+	}
+}
 
-		writeJSON(w, http.StatusOK, map[string]string{"token": raw})
+func RevokeAPIKey(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid api key id")
+			return
+		}
+
+		if err := services.RevokeAPIKey(db, user.ID, uint(id)); err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "api key not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func CreateAPIKey(db *gorm.DB) http.HandlerFunc {
+// ChangePassword changes the caller's own password.  Their other sessions
+// end; the one making the request stays valid.
+func ChangePassword(db *gorm.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := auth.UserFromContext(r.Context())
 		if user == nil {
@@ -62,31 +181,57 @@ func CreateAPIKey(db *gorm.DB) http.HandlerFunc {
 		}
 
 		var req struct {
-			Label string `json:"label"`
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		raw, hash, err := auth.GenerateToken()
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "token generation failed")
+		if err := auth.CheckPassword(req.CurrentPassword, user.PasswordHash); err != nil {
+			writeError(w, http.StatusForbidden, "current password is incorrect")
+			return
+		}
+		if err := services.ValidatePassword(req.NewPassword); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		token := models.Token{
-			UserID:    user.ID,
-			TokenHash: hash,
-			Type:      "apikey",
-			Label:     req.Label,
+		hash, err := auth.HashPassword(req.NewPassword)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "password hashing failed")
+			return
 		}
-		if err := db.Create(&token).Error; err != nil {
+		if err := services.SetPassword(db, user, hash, auth.TokenFromContext(r.Context()).ID); err != nil {
 			writeError(w, http.StatusInternalServerError, "database error")
 			return
 		}
+		auditTarget(r, "users/"+user.Username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
 
-		writeJSON(w, http.StatusCreated, map[string]string{"key": raw, "label": req.Label})
+// Logout ends the session the request was made with.  API keys are not
+// sessions and are revoked through DELETE /api/v1/auth/apikeys/{id}.
+func Logout(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := auth.TokenFromContext(r.Context())
+		if token == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if token.Type != "session" {
+			writeError(w, http.StatusBadRequest, "only sessions can log out; revoke api keys instead")
+			return
+		}
+		if err := db.Delete(token).Error; err != nil {
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			auditTarget(r, "users/"+user.Username)
+		}
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// RegisterBuilder is called by a builder at startup, authenticated with the
+// registration token.  The returned token is the builder's own credential
+// and is shown only once.  A name still in use by an online builder gets a
+// 409; a removed one gets a 403 until registrationToken is rotated.
+func RegisterBuilder(db *gorm.DB, registrationToken string, offlineAfter time.Duration) http.HandlerFunc {
+	registrationHash := auth.HashToken(registrationToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name    string   `json:"name"`
+			Version string   `json:"version"`
+			Labels  []string `json:"labels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		raw, hash, err := auth.GenerateToken()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "token generation failed")
+			return
+		}
+		b, err := services.RegisterBuilder(db, req.Name, req.Version, req.Labels, hash, registrationHash, offlineAfter)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidBuilderName) || errors.Is(err, services.ErrInvalidLabel) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrBuilderOnline) {
+				auditEvent(db, r, "builder:"+req.Name, "builder.register", "", http.StatusConflict)
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrBuilderRevoked) {
+				auditEvent(db, r, "builder:"+req.Name, "builder.register", "", http.StatusForbidden)
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+
+		auditEvent(db, r, "builder:"+b.Name, "builder.register", fmt.Sprintf("builders/%d", b.ID), http.StatusCreated)
+		writeJSON(w, http.StatusCreated, map[string]any{"id": b.ID, "name": b.Name, "token": raw})
+	}
+}
+
+func ListBuilders(db *gorm.DB, offlineAfter time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		builders, err := services.ListBuilders(db, offlineAfter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, builders)
+	}
+}
+
+// DeleteBuilder removes a builder and revokes its name under
+// registrationToken (see services.DeleteBuilder).
+func DeleteBuilder(db *gorm.DB, registrationToken string) http.HandlerFunc {
+	registrationHash := auth.HashToken(registrationToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid builder id")
+			return
+		}
+
+		if err := services.DeleteBuilder(db, uint(id), registrationHash); err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "builder not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -3,10 +3,13 @@ package routes
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/models"
 	"github.com/romain325/doc-thor/server/services"
 	"gorm.io/gorm"
 )
@@ -27,11 +30,13 @@ func CreateBuild(db *gorm.DB) http.HandlerFunc {
 		// ref and tag are optional; ignore decode errors from empty bodies.
 		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck
 
-		build, err := services.CreateBuild(db, project.ID, req.Ref, req.Tag)
+		build, err := services.CreateBuild(db, project.ID, req.Ref, req.Tag, "", services.TriggerManual)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to create build")
 			return
 		}
+		auditTarget(r, fmt.Sprintf("projects/%s/builds/%d", slug, build.ID))
+		auditChange(r, nil, build)
 		writeJSON(w, http.StatusCreated, build)
 	}
 }
@@ -92,12 +97,63 @@ func GetBuild(db *gorm.DB) http.HandlerFunc {
 	}
 }
 
+// maxLogChunk bounds the limit parameter of GetBuildLogs.
+const maxLogChunk = 1 << 20
+
+// GetBuildLogs pages through the full log of a build.  offset and limit are
+// in bytes; follow next_offset until it reaches total.
+func GetBuildLogs(db *gorm.DB, logs services.LogStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		idStr := chi.URLParam(r, "id")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid build id")
+			return
+		}
+
+		offset, limit := int64(0), 64<<10
+		if v := r.URL.Query().Get("offset"); v != "" {
+			if o, err := strconv.ParseInt(v, 10, 64); err == nil && o >= 0 {
+				offset = o
+			}
+		}
+		if v := r.URL.Query().Get("limit"); v != "" {
+			if l, err := strconv.Atoi(v); err == nil && l > 0 {
+				limit = min(l, maxLogChunk)
+			}
+		}
+
+		build, err := services.GetBuild(db, project.ID, uint(id))
+		if err != nil {
+			writeError(w, http.StatusNotFound, "build not found")
+			return
+		}
+		chunk, err := logs.Read(build, offset, limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read build log")
+			return
+		}
+		writeJSON(w, http.StatusOK, chunk)
+	}
+}
+
 // ClaimPendingBuild is the builder-facing poll endpoint.  It atomically claims
-// the oldest pending build (transitions it to running) and returns the job
-// payload the builder needs to start work.  Returns 204 when the queue is empty.
-func ClaimPendingBuild(db *gorm.DB) http.HandlerFunc {
+// the next pending build (see services.ClaimPendingBuild for the order) and
+// returns the job payload the builder needs to start work.  Returns 204 when
+// nothing this builder can take is pending.  baseDomain and docsScheme are
+// used to tell the build where it will be served.
+func ClaimPendingBuild(db *gorm.DB, logs services.LogStore, maxRunning int, baseDomain, docsScheme string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		build, project, err := services.ClaimPendingBuild(db)
+		builder := auth.BuilderFromContext(r.Context())
+		build, project, err := services.ClaimPendingBuild(db, maxRunning, builder)
 		if err != nil {
 			if errors.Is(err, services.ErrNotFound) {
 				w.WriteHeader(http.StatusNoContent)
@@ -107,21 +163,83 @@ func ClaimPendingBuild(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
-		writeJSON(w, http.StatusOK, map[string]string{
-			"id":           strconv.FormatUint(uint64(build.ID), 10),
-			"project_slug": project.Slug,
-			"version":      build.Tag,
-			"source_url":   project.SourceURL,
-			"ref":          build.Ref,
-			"docker_image": project.DockerImage,
+		env, err := services.ResolveProjectEnv(db, project.ID)
+		if err != nil {
+			// The build is already claimed; fail it rather than leave it
+			// stuck in running with no builder working on it.
+			services.ReportBuildResult(db, logs, builder.ID, build.ID, services.BuildReport{Status: "failed", Error: "resolve project variables: " + err.Error()}) //nolint:errcheck
+			writeError(w, http.StatusInternalServerError, "failed to resolve project variables")
+			return
+		}
+
+		versions, err := services.ListVersions(db, project.ID)
+		if err != nil {
+			services.ReportBuildResult(db, logs, builder.ID, build.ID, services.BuildReport{Status: "failed", Error: "list versions: " + err.Error()}) //nolint:errcheck
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		// Other published versions let generators render a version switcher.
+		others, latest := []string{}, ""
+		for _, v := range versions {
+			if !v.Published || v.Tag == build.Tag {
+				continue
+			}
+			others = append(others, v.Tag)
+			if v.IsLatest {
+				latest = v.Tag
+			}
+		}
+
+		writeJSON(w, http.StatusOK, buildJob{
+			ID:          strconv.FormatUint(uint64(build.ID), 10),
+			ProjectSlug: project.Slug,
+			Version:     build.Tag,
+			SourceURL:   project.SourceURL,
+			Ref:         build.Ref,
+			Commit:      build.Commit,
+			DockerImage: project.DockerImage,
+			Resources:   project.Resources,
+			Timeout:     project.BuildTimeout,
+			Env:         env,
+			BaseURL:     docsURL(docsScheme, baseDomain, project.Slug, build.Tag),
+			Versions:    others,
+			Latest:      latest,
 		})
 	}
 }
 
+// docsURL is the public URL a version is served at.  An empty version maps to
+// the bare project subdomain.
+func docsURL(scheme, baseDomain, slug, version string) string {
+	host := slug
+	if version != "" {
+		host = slug + "-" + version
+	}
+	return scheme + "://" + host + "." + baseDomain + "/"
+}
+
+// buildJob is the wire shape handed to a builder by ClaimPendingBuild.  It
+// mirrors the Job struct in builder/agent; the two must stay in sync.
+type buildJob struct {
+	ID          string                 `json:"id"`
+	ProjectSlug string                 `json:"project_slug"`
+	Version     string                 `json:"version"`
+	SourceURL   string                 `json:"source_url"`
+	Ref         string                 `json:"ref"`
+	Commit      string                 `json:"commit,omitempty"`
+	DockerImage string                 `json:"docker_image"`
+	Resources   *models.BuildResources `json:"resources,omitempty"`
+	Timeout     int                    `json:"timeout,omitempty"` // seconds; 0 = builder default
+	Env         map[string]string      `json:"env,omitempty"`
+	BaseURL     string                 `json:"base_url"`
+	Versions    []string               `json:"versions"` // other published versions
+	Latest      string                 `json:"latest,omitempty"`
+}
+
 // ReportBuildResult is the builder-facing endpoint for recording a completed
 // job.  The build must currently be in "running" state; any other state yields
 // 409 Conflict.
-func ReportBuildResult(db *gorm.DB) http.HandlerFunc {
+func ReportBuildResult(db *gorm.DB, logs services.LogStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseUint(idStr, 10, 64)
@@ -130,11 +248,7 @@ func ReportBuildResult(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
-		var req struct {
-			Status string `json:"status"`
-			Error  string `json:"error"`
-			Logs   string `json:"logs"`
-		}
+		var req services.BuildReport
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, http.StatusBadRequest, "invalid request body")
 			return
@@ -143,13 +257,22 @@ func ReportBuildResult(db *gorm.DB) http.HandlerFunc {
 			writeError(w, http.StatusBadRequest, "status must be success or failed")
 			return
 		}
+		if req.Storage != "" && req.Storage != services.StorageS3 && req.Storage != services.StorageLocal {
+			writeError(w, http.StatusBadRequest, "storage must be s3 or local")
+			return
+		}
 
-		build, err := services.ReportBuildResult(db, uint(id), req.Status, req.Logs, req.Error)
+		builder := auth.BuilderFromContext(r.Context())
+		build, err := services.ReportBuildResult(db, logs, builder.ID, uint(id), req)
 		if err != nil {
 			if errors.Is(err, services.ErrNotFound) {
 				writeError(w, http.StatusNotFound, "build not found")
 				return
 			}
+			if errors.Is(err, services.ErrBuildNotClaimed) {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
 			if errors.Is(err, services.ErrBuildNotRunning) {
 				writeError(w, http.StatusConflict, "build is not in running state")
 				return
@@ -2,17 +2,21 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/models"
 	"github.com/romain325/doc-thor/server/services"
 	"gorm.io/gorm"
 )
 
-// RegisterDiscoveryRoutes registers project discovery routes.
-func RegisterDiscoveryRoutes(r chi.Router, db *gorm.DB) {
-	r.Post("/api/v1/integrations/{name}/discover", discoverProjects(db))
-	r.Post("/api/v1/projects/import", importProject(db))
+// RegisterDiscoveryRoutes registers project discovery routes.  Both create
+// or lead to creating projects, so they need the maintainer role.
+func RegisterDiscoveryRoutes(r chi.Router, db *gorm.DB, policy services.ImagePolicy) {
+	maintainer := RequireRole(models.RoleMaintainer)
+	r.With(maintainer).Post("/api/v1/integrations/{name}/discover", discoverProjects(db))
+	r.With(maintainer).Post("/api/v1/projects/import", importProject(db, policy))
 }
 
 func discoverProjects(db *gorm.DB) http.HandlerFunc {
@@ -53,7 +57,7 @@ func discoverProjects(db *gorm.DB) http.HandlerFunc {
 	}
 }
 
-func importProject(db *gorm.DB) http.HandlerFunc {
+func importProject(db *gorm.DB, policy services.ImagePolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req services.ImportProjectRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -72,8 +76,13 @@ func importProject(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
-		project, err := services.ImportProject(r.Context(), db, req)
+		project, err := services.ImportProject(r.Context(), db, policy, req)
 		if err != nil {
+			if errors.Is(err, services.ErrImageNotAllowed) || errors.Is(err, services.ErrInvalidLabel) ||
+				errors.Is(err, services.ErrInvalidVisibility) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			if err == services.ErrAlreadyExists {
 				writeError(w, http.StatusConflict, "Project with this slug already exists")
 				return
@@ -86,6 +95,9 @@ func importProject(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
+		grantCreator(db, r, project.ID)
+		auditTarget(r, "projects/"+project.Slug)
+		auditChange(r, nil, project)
 		writeJSON(w, http.StatusCreated, project)
 	}
 }
@@ -0,0 +1,326 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/oidc"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// DocsAuthSettings configures access to docs sites that are not public.
+// nginx serves these endpoints on every guarded docs host under
+// /_doc-thor/ (see services.SyncNginxConfig), so the cookie they set
+// belongs to the docs domain rather than to the API's.  It holds a docs
+// token, which the API refuses: docs hosts serve project HTML, and a
+// cookie readable there must not be worth more than the docs themselves.
+type DocsAuthSettings struct {
+	// BaseDomain is the domain docs are served under; the cookie is shared
+	// by all of its subdomains.
+	BaseDomain string
+	// Scheme is the one docs are served with (DOCS_SCHEME).  It sets the
+	// sign-in redirects and the cookies' Secure flag; a forwarded header
+	// would let any client that reaches the server pick them.
+	Scheme          string
+	SessionTTLHours int
+	PasswordLogin   bool
+	SSO             *SSOSettings // nil without single sign-on
+	// Guard limits failed password sign-ins, shared with the API's login.
+	Guard LoginGuard
+}
+
+const (
+	docsSessionCookie = "docthor_session"
+	// docsSSOCookie ties a single sign-on login to the browser that
+	// started it, so nobody can finish theirs in someone else's.
+	docsSSOCookie = "docthor_sso"
+	// docsCodeTTL bounds how long SSOCallback's one-time code waits for
+	// DocsSession.
+	docsCodeTTL = time.Minute
+)
+
+// DocsAuthCheck answers nginx's auth_request for a page of the project in
+// X-Doc-Project: 204 to serve it, 401 to show the login page, 403 when the
+// signed-in user may not read it.
+func DocsAuthCheck(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := services.GetProject(db, r.Header.Get("X-Doc-Project"))
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		user := docsUser(db, r)
+		switch {
+		case services.CanReadDocs(db, user, p):
+			w.WriteHeader(http.StatusNoContent)
+		case user == nil:
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}
+}
+
+// DocsLoginPage shows the login form.  nginx serves it in place of any
+// guarded page while the visitor is not signed in, then sends them back to
+// that page.  With only single sign-on enabled it goes straight there.
+func DocsLoginPage(s DocsAuthSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rd := r.Header.Get("X-Original-URI")
+		status := http.StatusUnauthorized
+		if rd == "" || strings.HasPrefix(rd, "/_doc-thor/") {
+			rd, status = r.URL.Query().Get("rd"), http.StatusOK
+		}
+		rd = localPath(rd)
+
+		if !s.PasswordLogin && s.SSO != nil {
+			http.Redirect(w, r, "/_doc-thor/sso?rd="+url.QueryEscape(rd), http.StatusFound)
+			return
+		}
+		renderDocsLogin(w, s, status, rd, "")
+	}
+}
+
+// DocsLogin signs a visitor in with their password and sets the docs
+// cookie.
+func DocsLogin(db *gorm.DB, s DocsAuthSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rd := localPath(r.PostFormValue("rd"))
+		if !s.PasswordLogin {
+			renderDocsLogin(w, s, http.StatusForbidden, rd, "Password login is disabled.")
+			return
+		}
+
+		ip, username := clientIP(r), r.PostFormValue("username")
+		if wait, ok := s.Guard.check(ip, username); !ok {
+			setRetryAfter(w, wait)
+			renderDocsLogin(w, s, http.StatusTooManyRequests, rd, "Too many failed sign-ins; try again later.")
+			return
+		}
+		var user models.User
+		if err := db.Where("username = ?", username).Limit(1).Find(&user).Error; err != nil ||
+			user.ID == 0 || auth.CheckPassword(r.PostFormValue("password"), user.PasswordHash) != nil {
+			s.Guard.fail(ip, username)
+			auditEvent(db, r, username, "auth.login", "users/"+username, http.StatusUnauthorized)
+			renderDocsLogin(w, s, http.StatusUnauthorized, rd, "Invalid username or password.")
+			return
+		}
+		s.Guard.succeed(username)
+		if err := signInDocs(db, w, r, s, user.ID); err != nil {
+			renderDocsLogin(w, s, http.StatusInternalServerError, rd, "Sign-in failed, please try again.")
+			return
+		}
+		auditEvent(db, r, user.Username, "auth.login", "users/"+user.Username, http.StatusOK)
+		http.Redirect(w, r, rd, http.StatusSeeOther)
+	}
+}
+
+// DocsSSO starts a single sign-on login that ends at DocsSession on the
+// docs host the visitor came from.  The login is bound to this browser by
+// a cookie whose hash rides along in the return URL.
+func DocsSSO(s DocsAuthSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.SSO == nil {
+			http.NotFound(w, r)
+			return
+		}
+		host := r.Host
+		if !underDomain(host, s.BaseDomain) {
+			http.Error(w, "not a docs host", http.StatusBadRequest)
+			return
+		}
+		bind, bindHash, err := auth.GenerateToken()
+		if err != nil {
+			http.Error(w, "sign-in failed", http.StatusInternalServerError)
+			return
+		}
+		returnTo := s.Scheme + "://" + host + "/_doc-thor/session?rd=" + url.QueryEscape(localPath(r.URL.Query().Get("rd"))) +
+			"&bind=" + bindHash
+
+		authURL, err := s.SSO.Provider.AuthURL(r.Context(), returnTo)
+		if err != nil {
+			log.Printf("sso: %v", err)
+			http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     docsSSOCookie,
+			Value:    bind,
+			Path:     "/_doc-thor/",
+			MaxAge:   int(oidc.LoginTTL / time.Second),
+			HttpOnly: true,
+			Secure:   s.Scheme == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// DocsSession finishes a single sign-on login: it redeems the one-time
+// code SSOCallback sent along and sets the docs cookie.
+func DocsSession(db *gorm.DB, s DocsAuthSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		rd := localPath(q.Get("rd"))
+		http.SetCookie(w, &http.Cookie{Name: docsSSOCookie, Path: "/_doc-thor/", MaxAge: -1})
+		if e := q.Get("error"); e != "" {
+			renderDocsLogin(w, s, http.StatusUnauthorized, rd, e)
+			return
+		}
+		var bind string
+		if c, err := r.Cookie(docsSSOCookie); err == nil {
+			bind = c.Value
+		}
+		userID, err := redeemDocsCode(db, q.Get("code"), bind)
+		if err == nil {
+			err = signInDocs(db, w, r, s, userID)
+		}
+		if err != nil {
+			renderDocsLogin(w, s, http.StatusUnauthorized, rd, "Sign-in failed, please try again.")
+			return
+		}
+		http.Redirect(w, r, rd, http.StatusSeeOther)
+	}
+}
+
+// DocsLogout ends the docs sign-in in the cookie and clears it.
+func DocsLogout(db *gorm.DB, s DocsAuthSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(docsSessionCookie); err == nil {
+			if _, token, err := auth.DocsUser(db, c.Value); err == nil {
+				db.Delete(token)
+			}
+		}
+		setDocsCookie(w, r, s, "", time.Unix(0, 0))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+func docsUser(db *gorm.DB, r *http.Request) *models.User {
+	c, err := r.Cookie(docsSessionCookie)
+	if err != nil {
+		return nil
+	}
+	user, _, err := auth.DocsUser(db, c.Value)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// signInDocs issues a docs token for a user and stores it in the cookie.
+func signInDocs(db *gorm.DB, w http.ResponseWriter, r *http.Request, s DocsAuthSettings, userID uint) error {
+	ttl := time.Duration(s.SessionTTLHours) * time.Hour
+	raw, err := issueToken(db, userID, "docs", "", ttl)
+	if err != nil {
+		return err
+	}
+	setDocsCookie(w, r, s, raw, time.Now().Add(ttl))
+	return nil
+}
+
+// issueDocsCode creates the one-time code SSOCallback hands to DocsSession
+// for the login bound to bindHash.
+func issueDocsCode(db *gorm.DB, userID uint, bindHash string) (string, error) {
+	return issueToken(db, userID, "docs_code", bindHash, docsCodeTTL)
+}
+
+// redeemDocsCode consumes a code from issueDocsCode and returns the user it
+// was issued to.  bind is the raw value of the browser's docsSSOCookie.
+func redeemDocsCode(db *gorm.DB, code, bind string) (uint, error) {
+	var token models.Token
+	if err := db.Where("token_hash = ? AND type = ?", auth.HashToken(code), "docs_code").Limit(1).Find(&token).Error; err != nil || token.ID == 0 {
+		return 0, auth.ErrUnauthorized
+	}
+	// Codes are single use, even when the checks below fail.
+	if db.Delete(&token).RowsAffected != 1 {
+		return 0, auth.ErrUnauthorized
+	}
+	if token.ExpiresAt == nil || token.ExpiresAt.Before(time.Now()) ||
+		subtle.ConstantTimeCompare([]byte(token.Label), []byte(auth.HashToken(bind))) != 1 {
+		return 0, auth.ErrUnauthorized
+	}
+	return token.UserID, nil
+}
+
+func setDocsCookie(w http.ResponseWriter, r *http.Request, s DocsAuthSettings, value string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     docsSessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if underDomain(r.Host, s.BaseDomain) {
+		c.Domain = s.BaseDomain
+	}
+	http.SetCookie(w, c)
+}
+
+// underDomain reports whether host, which may carry a port, is a subdomain
+// of domain.
+func underDomain(host, domain string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return domain != "" && strings.HasSuffix(host, "."+domain)
+}
+
+// localPath keeps redirects on the docs host: anything but a plain
+// absolute path becomes "/".
+func localPath(rd string) string {
+	if !strings.HasPrefix(rd, "/") || strings.HasPrefix(rd, "//") || strings.HasPrefix(rd, "/\\") {
+		return "/"
+	}
+	return rd
+}
+
+var docsLoginTemplate = template.Must(template.New("login").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in - doc-thor</title>
+<style>
+body { font-family: sans-serif; max-width: 22rem; margin: 4rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; margin: .4rem 0; box-sizing: border-box; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Sign in</h1>
+<p>These docs are not public.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .PasswordLogin}}<form method="post" action="/_doc-thor/login">
+<input type="hidden" name="rd" value="{{.Redirect}}">
+<label>Username <input name="username" autocomplete="username" required autofocus></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in</button>
+</form>{{end}}
+{{if .SSO}}<p><a href="/_doc-thor/sso?rd={{.Redirect}}">Sign in with single sign-on</a></p>{{end}}
+</body>
+</html>
+`))
+
+func renderDocsLogin(w http.ResponseWriter, s DocsAuthSettings, status int, rd, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	docsLoginTemplate.Execute(w, struct {
+		Redirect      string
+		Error         string
+		PasswordLogin bool
+		SSO           bool
+	}{rd, errMsg, s.PasswordLogin, s.SSO != nil}) //nolint:errcheck
+}
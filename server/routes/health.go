@@ -2,8 +2,11 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/romain325/doc-thor/server/discovery"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
 )
 
 func Health() http.HandlerFunc {
@@ -12,11 +15,18 @@ func Health() http.HandlerFunc {
 	}
 }
 
-func Backends(builderEndpoints []string, storageEndpoint string, storageUseSSL bool) http.HandlerFunc {
+// Backends reports the registered builders, healthy while they keep
+// polling, and the storage endpoint.
+func Backends(db *gorm.DB, offlineAfter time.Duration, storageEndpoint string, storageUseSSL bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		builders, err := services.ListBuilders(db, offlineAfter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
 		var statuses []discovery.BackendStatus
-		for _, url := range builderEndpoints {
-			statuses = append(statuses, discovery.CheckBuilder(url))
+		for _, b := range builders {
+			statuses = append(statuses, discovery.BuilderStatus(b))
 		}
 		statuses = append(statuses, discovery.CheckStorage(storageEndpoint, storageUseSSL))
 		writeJSON(w, http.StatusOK, statuses)
@@ -11,16 +11,18 @@ import (
 	"gorm.io/gorm"
 )
 
-// RegisterVCSIntegrationRoutes registers VCS integration routes.
+// RegisterVCSIntegrationRoutes registers VCS integration routes.  Anyone
+// signed in may look at integrations; only admins may change them.
 func RegisterVCSIntegrationRoutes(r chi.Router, db *gorm.DB) {
+	admin := RequireRole(models.RoleAdmin)
 	r.Route("/api/v1/integrations", func(r chi.Router) {
-		r.Post("/", createVCSIntegration(db))
+		r.With(admin).Post("/", createVCSIntegration(db))
 		r.Get("/", listVCSIntegrations(db))
 		r.Route("/{name}", func(r chi.Router) {
 			r.Get("/", getVCSIntegration(db))
-			r.Put("/", updateVCSIntegration(db))
-			r.Delete("/", deleteVCSIntegration(db))
-			r.Post("/test", testVCSIntegration(db))
+			r.With(admin).Put("/", updateVCSIntegration(db))
+			r.With(admin).Delete("/", deleteVCSIntegration(db))
+			r.With(admin).Post("/test", testVCSIntegration(db))
 		})
 	})
 }
@@ -83,6 +85,8 @@ func createVCSIntegration(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
+		auditTarget(r, "integrations/"+integration.Name)
+		auditChange(r, nil, integration)
 		writeJSON(w, http.StatusCreated, integration)
 	}
 }
@@ -143,6 +147,7 @@ func updateVCSIntegration(db *gorm.DB) http.HandlerFunc {
 			updates.Enabled = *req.Enabled
 		}
 
+		before, _ := services.GetVCSIntegration(db, name)
 		integration, err := services.UpdateVCSIntegration(db, name, updates)
 		if err != nil {
 			if err == services.ErrNotFound {
@@ -153,6 +158,7 @@ func updateVCSIntegration(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
+		auditChange(r, before, integration)
 		writeJSON(w, http.StatusOK, integration)
 	}
 }
@@ -161,6 +167,7 @@ func deleteVCSIntegration(db *gorm.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
 
+		before, _ := services.GetVCSIntegration(db, name)
 		if err := services.DeleteVCSIntegration(db, name); err != nil {
 			if err == services.ErrNotFound {
 				writeError(w, http.StatusNotFound, "Integration not found")
@@ -169,6 +176,7 @@ func deleteVCSIntegration(db *gorm.DB) http.HandlerFunc {
 			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		auditChange(r, before, nil)
 
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// grantCreator makes the creator of a project its admin, so a maintainer
+// can manage the members of, and delete, the projects they create.  Admins
+// need no membership.  Best-effort: the project exists either way.
+func grantCreator(db *gorm.DB, r *http.Request, projectID uint) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil || user.Role == models.RoleAdmin {
+		return
+	}
+	services.SetMember(db, projectID, user.Username, models.RoleAdmin) //nolint:errcheck
+}
+
+func ListMembers(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		members, err := services.ListMembers(db, project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, members)
+	}
+}
+
+// SetMember adds a user to a project or changes their role on it.
+func SetMember(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		before, _ := services.GetMember(db, project.ID, chi.URLParam(r, "username"))
+		m, err := services.SetMember(db, project.ID, chi.URLParam(r, "username"), req.Role)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRole) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "user not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		auditChange(r, before, m)
+		writeJSON(w, http.StatusOK, m)
+	}
+}
+
+func RemoveMember(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		before, _ := services.GetMember(db, project.ID, chi.URLParam(r, "username"))
+		if err := services.RemoveMember(db, project.ID, chi.URLParam(r, "username")); err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "member not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		auditChange(r, before, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
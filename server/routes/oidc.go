@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/romain325/doc-thor/server/oidc"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// SSOSettings configures single sign-on through an OIDC provider.
+type SSOSettings struct {
+	Provider        *oidc.Provider
+	UsernameClaim   string
+	GroupsClaim     string
+	Roles           services.SSORoleMapping
+	SessionTTLHours int
+}
+
+// SSOLogin sends the browser to the identity provider.  A redirect_uri on
+// the loopback interface, where the CLI listens, receives the session token
+// once the login completes; without one the callback returns it as JSON.
+func SSOLogin(sso SSOSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		returnTo := r.URL.Query().Get("redirect_uri")
+		if returnTo != "" && !isLoopbackURL(returnTo) {
+			writeError(w, http.StatusBadRequest, "redirect_uri must be an http URL on localhost")
+			return
+		}
+		authURL, err := sso.Provider.AuthURL(r.Context(), returnTo)
+		if err != nil {
+			log.Printf("sso: %v", err)
+			writeError(w, http.StatusBadGateway, "identity provider unavailable")
+			return
+		}
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// SSOCallback is where the identity provider sends the browser back.  It
+// redeems the code, maps the user's claims to an account and role, and
+// issues the same session token as a password login.  Logins started on a
+// docs host (see DocsSSO) get a one-time code for DocsSession instead.
+func SSOCallback(db *gorm.DB, sso SSOSettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			returnTo, _ := sso.Provider.ReturnTo(q.Get("state"))
+			ssoFail(w, r, returnTo, http.StatusUnauthorized, "identity provider: "+e)
+			return
+		}
+
+		claims, returnTo, err := sso.Provider.Finish(r.Context(), q.Get("state"), q.Get("code"))
+		if err != nil {
+			if errors.Is(err, oidc.ErrUnknownState) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("sso: %v", err)
+			ssoFail(w, r, returnTo, http.StatusUnauthorized, "sso login failed")
+			return
+		}
+
+		// The identity is the provider's from here on: refusals are audited.
+		username := claims.String(sso.UsernameClaim)
+		refuse := func(status int, msg string) {
+			auditEvent(db, r, username, "auth.sso_login", "users/"+username, status)
+			ssoFail(w, r, returnTo, status, msg)
+		}
+		role := sso.Roles.Role(claims.Strings(sso.GroupsClaim))
+		if role == "" {
+			refuse(http.StatusForbidden, "not a member of any group allowed to sign in")
+			return
+		}
+		user, err := services.SSOUser(db, claims.String("sub"), username, role, sso.Roles.Configured())
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidUsername) {
+				refuse(http.StatusForbidden, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrAlreadyExists) {
+				refuse(http.StatusConflict, "username already belongs to another account")
+				return
+			}
+			ssoFail(w, r, returnTo, http.StatusInternalServerError, "database error")
+			return
+		}
+
+		if returnTo != "" && !isLoopbackURL(returnTo) {
+			code, err := issueDocsCode(db, user.ID, queryParam(returnTo, "bind"))
+			if err != nil {
+				ssoFail(w, r, returnTo, http.StatusInternalServerError, "session creation failed")
+				return
+			}
+			auditEvent(db, r, user.Username, "auth.sso_login", "users/"+user.Username, http.StatusOK)
+			http.Redirect(w, r, withQuery(returnTo, "code", code), http.StatusFound)
+			return
+		}
+		raw, err := issueSession(db, user.ID, sso.SessionTTLHours)
+		if err != nil {
+			ssoFail(w, r, returnTo, http.StatusInternalServerError, "session creation failed")
+			return
+		}
+		auditEvent(db, r, user.Username, "auth.sso_login", "users/"+user.Username, http.StatusOK)
+		if returnTo != "" {
+			http.Redirect(w, r, withQuery(returnTo, "token", raw), http.StatusFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"token": raw})
+	}
+}
+
+// ssoFail reports a failed login to the CLI or docs host waiting at
+// returnTo, if any.
+func ssoFail(w http.ResponseWriter, r *http.Request, returnTo string, status int, msg string) {
+	if returnTo != "" {
+		http.Redirect(w, r, withQuery(returnTo, "error", msg), http.StatusFound)
+		return
+	}
+	writeError(w, status, msg)
+}
+
+func withQuery(rawURL, key, value string) string {
+	u, _ := url.Parse(rawURL)
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func queryParam(rawURL, key string) string {
+	u, _ := url.Parse(rawURL)
+	return u.Query().Get(key)
+}
+
+func isLoopbackURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	host := u.Hostname()
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
@@ -11,7 +11,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func CreateProject(db *gorm.DB) http.HandlerFunc {
+func CreateProject(db *gorm.DB, policy services.ImagePolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var p models.Project
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -22,7 +22,13 @@ func CreateProject(db *gorm.DB) http.HandlerFunc {
 			writeError(w, http.StatusBadRequest, "slug, name, and source_url are required")
 			return
 		}
-		if err := services.CreateProject(db, &p); err != nil {
+		if err := services.CreateProject(db, policy, &p); err != nil {
+			if errors.Is(err, services.ErrInvalidResources) || errors.Is(err, services.ErrInvalidBuildTimeout) ||
+				errors.Is(err, services.ErrImageNotAllowed) || errors.Is(err, services.ErrInvalidLabel) ||
+				errors.Is(err, services.ErrInvalidVisibility) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			if errors.Is(err, services.ErrAlreadyExists) {
 				writeError(w, http.StatusConflict, "project with this slug already exists")
 				return
@@ -30,17 +36,21 @@ func CreateProject(db *gorm.DB) http.HandlerFunc {
 			writeError(w, http.StatusInternalServerError, "database error")
 			return
 		}
+		grantCreator(db, r, p.ID)
+		auditTarget(r, "projects/"+p.Slug)
+		auditChange(r, nil, p)
 		writeJSON(w, http.StatusCreated, p)
 	}
 }
 
 // projectWithVersions is the wire shape returned by ListProjects.  It embeds
 // the base Project and adds the published version tags that generate.py needs
-// to render nginx server blocks.
+// to render nginx server blocks, with the storage backend of each.
 type projectWithVersions struct {
 	models.Project
-	Versions []string `json:"versions"`
-	Latest   string   `json:"latest"`
+	Versions []string          `json:"versions"`
+	Latest   string            `json:"latest"`
+	Storage  map[string]string `json:"storage"` // version tag → storage backend
 }
 
 func ListProjects(db *gorm.DB) http.HandlerFunc {
@@ -58,12 +68,13 @@ func ListProjects(db *gorm.DB) http.HandlerFunc {
 				writeError(w, http.StatusInternalServerError, "database error")
 				return
 			}
-			pwv := projectWithVersions{Project: p, Versions: []string{}}
+			pwv := projectWithVersions{Project: p, Versions: []string{}, Storage: map[string]string{}}
 			for _, v := range versions {
 				if !v.Published {
 					continue
 				}
 				pwv.Versions = append(pwv.Versions, v.Tag)
+				pwv.Storage[v.Tag] = v.Storage
 				if v.IsLatest {
 					pwv.Latest = v.Tag
 				}
@@ -87,31 +98,46 @@ func GetProject(db *gorm.DB) http.HandlerFunc {
 	}
 }
 
-func UpdateProject(db *gorm.DB) http.HandlerFunc {
+// UpdateProject applies changes to a project.  A visibility change rewrites
+// its nginx config so the docs are guarded, or opened, right away.
+func UpdateProject(db *gorm.DB, policy services.ImagePolicy, nginxDir string, storage services.StorageLocations, authUpstream string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slug := chi.URLParam(r, "slug")
-		var updates models.Project
+		var updates services.ProjectUpdate
 		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
 			writeError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		p, err := services.UpdateProject(db, slug, &updates)
+		before, _ := services.GetProject(db, slug)
+		p, err := services.UpdateProject(db, policy, slug, &updates)
 		if err != nil {
 			if errors.Is(err, services.ErrNotFound) {
 				writeError(w, http.StatusNotFound, "project not found")
 				return
 			}
+			if errors.Is(err, services.ErrInvalidResources) || errors.Is(err, services.ErrInvalidBuildTimeout) ||
+				errors.Is(err, services.ErrImageNotAllowed) || errors.Is(err, services.ErrInvalidLabel) ||
+				errors.Is(err, services.ErrInvalidVisibility) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			writeError(w, http.StatusInternalServerError, "update failed")
 			return
 		}
+		if updates.Visibility != "" {
+			// best-effort nginx sync; non-fatal if it fails
+			services.SyncNginxConfig(db, p, nginxDir, storage, authUpstream) //nolint:errcheck
+		}
+		auditChange(r, before, p)
 		writeJSON(w, http.StatusOK, p)
 	}
 }
 
-func DeleteProject(db *gorm.DB) http.HandlerFunc {
+func DeleteProject(db *gorm.DB, logs services.LogStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slug := chi.URLParam(r, "slug")
-		if err := services.DeleteProject(db, slug); err != nil {
+		before, _ := services.GetProject(db, slug)
+		if err := services.DeleteProject(db, logs, slug); err != nil {
 			if errors.Is(err, services.ErrNotFound) {
 				writeError(w, http.StatusNotFound, "project not found")
 				return
@@ -119,6 +145,7 @@ func DeleteProject(db *gorm.DB) http.HandlerFunc {
 			writeError(w, http.StatusInternalServerError, "delete failed")
 			return
 		}
+		auditChange(r, before, nil)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romain325/doc-thor/server/ratelimit"
+)
+
+// LoginGuard slows down password guessing: an address, or an account,
+// that fails to sign in too often is locked out for a while.  The account
+// lockout catches guessing spread over many addresses; the address
+// lockout catches one client trying many accounts.  Either may be nil.
+type LoginGuard struct {
+	PerIP   *ratelimit.Lockout
+	PerUser *ratelimit.Lockout
+}
+
+// check reports whether a sign-in may be attempted and, if not, how long
+// until it may.
+func (g LoginGuard) check(ip, username string) (time.Duration, bool) {
+	if wait, ok := g.PerIP.Check(ip); !ok {
+		return wait, false
+	}
+	return g.PerUser.Check(strings.ToLower(username))
+}
+
+// fail counts a failed sign-in.
+func (g LoginGuard) fail(ip, username string) {
+	g.PerIP.Fail(ip)
+	if username != "" {
+		g.PerUser.Fail(strings.ToLower(username))
+	}
+}
+
+// succeed clears the account's failures.  The address keeps its own, so a
+// client holding one valid account cannot use it to reset its count while
+// guessing others.
+func (g LoginGuard) succeed(username string) {
+	g.PerUser.Reset(strings.ToLower(username))
+}
+
+// writeRateLimited answers 429 with the number of seconds to wait in
+// Retry-After.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration, message string) {
+	setRetryAfter(w, wait)
+	writeError(w, http.StatusTooManyRequests, message)
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// trustedProxies are the peers whose X-Real-IP header clientIP believes.
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the addresses or CIDR ranges of the reverse
+// proxies in front of the server, such as the nginx serving docs sign-in
+// pages.
+func SetTrustedProxies(list []string) error {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return fmt.Errorf("trusted proxy %q: invalid address", s)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 128
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+// clientIP is the address the request came from.  X-Real-IP is believed
+// only from a trusted proxy: anyone else could forge it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if real := r.Header.Get("X-Real-IP"); real != "" && net.ParseIP(real) != nil {
+		if peer := net.ParseIP(host); peer != nil {
+			for _, n := range trustedProxies {
+				if n.Contains(peer) {
+					return real
+				}
+			}
+		}
+	}
+	return host
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+func ListSchedules(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		schedules, err := services.ListSchedules(db, project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, schedules)
+	}
+}
+
+// CreateSchedule adds a cron schedule to a project.  enabled and catch_up
+// default to true.
+func CreateSchedule(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		var req struct {
+			Cron    string `json:"cron"`
+			Ref     string `json:"ref"`
+			Tag     string `json:"tag"`
+			Enabled *bool  `json:"enabled"`
+			CatchUp *bool  `json:"catch_up"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Cron == "" {
+			writeError(w, http.StatusBadRequest, "cron is required")
+			return
+		}
+
+		s := &models.BuildSchedule{
+			ProjectID: project.ID,
+			Cron:      req.Cron,
+			Ref:       req.Ref,
+			Tag:       req.Tag,
+			Enabled:   req.Enabled == nil || *req.Enabled,
+			CatchUp:   req.CatchUp == nil || *req.CatchUp,
+		}
+		if err := services.CreateSchedule(db, s); err != nil {
+			if errors.Is(err, services.ErrInvalidCron) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to create schedule")
+			return
+		}
+		auditTarget(r, fmt.Sprintf("projects/%s/schedules/%d", slug, s.ID))
+		auditChange(r, nil, s)
+		writeJSON(w, http.StatusCreated, s)
+	}
+}
+
+func UpdateSchedule(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		var req services.ScheduleUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		before, _ := services.GetSchedule(db, project.ID, uint(id))
+		s, err := services.UpdateSchedule(db, project.ID, uint(id), req)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrNotFound):
+				writeError(w, http.StatusNotFound, "schedule not found")
+			case errors.Is(err, services.ErrInvalidCron):
+				writeError(w, http.StatusBadRequest, err.Error())
+			default:
+				writeError(w, http.StatusInternalServerError, "update failed")
+			}
+			return
+		}
+		auditChange(r, before, s)
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+func DeleteSchedule(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		before, _ := services.GetSchedule(db, project.ID, uint(id))
+		if err := services.DeleteSchedule(db, project.ID, uint(id)); err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "schedule not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		auditChange(r, before, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
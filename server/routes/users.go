@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/auth"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+func CreateUser(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if err := services.ValidatePassword(req.Password); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "password hashing failed")
+			return
+		}
+
+		user, err := services.CreateUser(db, req.Username, hash, req.Role)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidUsername) || errors.Is(err, services.ErrInvalidRole) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrAlreadyExists) {
+				writeError(w, http.StatusConflict, "user with this username already exists")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		auditTarget(r, "users/"+user.Username)
+		auditChange(r, nil, user)
+		writeJSON(w, http.StatusCreated, user)
+	}
+}
+
+func ListUsers(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := services.ListUsers(db)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+	}
+}
+
+func DeleteUser(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before, _ := services.GetUser(db, chi.URLParam(r, "username"))
+		if err := services.DeleteUser(db, chi.URLParam(r, "username")); err != nil {
+			if errors.Is(err, services.ErrLastAdmin) {
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "user not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		auditChange(r, before, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetUserPassword resets another user's password and signs them out.
+func SetUserPassword(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if err := services.ValidatePassword(req.Password); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		user, err := services.GetUser(db, chi.URLParam(r, "username"))
+		if err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "user not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "password hashing failed")
+			return
+		}
+		if err := services.SetPassword(db, user, hash, 0); err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		auditTarget(r, "users/"+user.Username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetUserRole changes a user's global role.
+func SetUserRole(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		before, _ := services.GetUser(db, chi.URLParam(r, "username"))
+		user, err := services.SetUserRole(db, chi.URLParam(r, "username"), req.Role)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRole) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrLastAdmin) {
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "user not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		auditTarget(r, "users/"+user.Username)
+		auditChange(r, before, user)
+		writeJSON(w, http.StatusOK, user)
+	}
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romain325/doc-thor/server/secrets"
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+func ListProjectVariables(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		vars, err := services.ListProjectVariables(db, project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, vars)
+	}
+}
+
+// SetProjectVariable creates or replaces a single variable.  Secret values are
+// write-only: they are encrypted on the way in and never returned.
+func SetProjectVariable(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		key := chi.URLParam(r, "key")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		var req struct {
+			Value  string `json:"value"`
+			Secret bool   `json:"secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		before, _ := services.GetProjectVariable(db, project.ID, key)
+		v, err := services.SetProjectVariable(db, project.ID, key, req.Value, req.Secret)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidVariableKey) || errors.Is(err, services.ErrReservedVariableKey) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if errors.Is(err, secrets.ErrNotConfigured) {
+				writeError(w, http.StatusServiceUnavailable, "secret storage is not configured on this server")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		auditChange(r, before, v)
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func DeleteProjectVariable(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		key := chi.URLParam(r, "key")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+
+		before, _ := services.GetProjectVariable(db, project.ID, key)
+		if err := services.DeleteProjectVariable(db, project.ID, key); err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "variable not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		auditChange(r, before, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
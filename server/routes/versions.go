@@ -3,7 +3,10 @@ package routes
 import (
 	"encoding/json"
 	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"path"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/romain325/doc-thor/server/services"
@@ -27,7 +30,7 @@ func ListVersions(db *gorm.DB) http.HandlerFunc {
 	}
 }
 
-func UpdateVersion(db *gorm.DB, nginxDir, storageEndpoint string) http.HandlerFunc {
+func UpdateVersion(db *gorm.DB, nginxDir string, storage services.StorageLocations, authUpstream string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slug := chi.URLParam(r, "slug")
 		ver := chi.URLParam(r, "ver")
@@ -59,6 +62,7 @@ func UpdateVersion(db *gorm.DB, nginxDir, storageEndpoint string) http.HandlerFu
 			return
 		}
 
+		before, _ := services.GetVersion(db, project.ID, ver)
 		version, err := services.UpdateVersion(db, project.ID, ver, updates)
 		if err != nil {
 			if errors.Is(err, services.ErrNotFound) {
@@ -70,8 +74,113 @@ func UpdateVersion(db *gorm.DB, nginxDir, storageEndpoint string) http.HandlerFu
 		}
 
 		// best-effort nginx sync; non-fatal if it fails
-		services.SyncNginxConfig(db, project, nginxDir, storageEndpoint) //nolint:errcheck
+		services.SyncNginxConfig(db, project, nginxDir, storage, authUpstream) //nolint:errcheck
 
+		auditChange(r, before, version)
 		writeJSON(w, http.StatusOK, version)
 	}
 }
+
+// ListVersionFiles returns the output manifest of a version.
+func ListVersionFiles(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		ver := chi.URLParam(r, "ver")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		version, err := services.GetVersion(db, project.ID, ver)
+		if err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "version not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		files, err := services.ListVersionFiles(db, version.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		writeJSON(w, http.StatusOK, files)
+	}
+}
+
+// DiffVersions compares the pages of two versions of a project: added and
+// removed paths, and a text diff of each modified page.
+func DiffVersions(db *gorm.DB, storage services.StorageLocations) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		diff, err := services.DiffVersions(r.Context(), db, storage, project, chi.URLParam(r, "ver"), chi.URLParam(r, "to"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrNotFound):
+				writeError(w, http.StatusNotFound, "version not found")
+			case errors.Is(err, services.ErrNoManifest):
+				writeError(w, http.StatusConflict, err.Error()+"; rebuild it to compare")
+			default:
+				writeError(w, http.StatusInternalServerError, "diff failed")
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, diff)
+	}
+}
+
+// DownloadVersionArchive streams the archive of a version's output from
+// whichever storage backend the version lives on.
+func DownloadVersionArchive(db *gorm.DB, storage services.StorageLocations) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := chi.URLParam(r, "slug")
+		ver := chi.URLParam(r, "ver")
+
+		project, err := services.GetProject(db, slug)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		version, err := services.GetVersion(db, project.ID, ver)
+		if err != nil {
+			if errors.Is(err, services.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "version not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if version.Archive == "" {
+			writeError(w, http.StatusNotFound, "no archive for this version")
+			return
+		}
+
+		body, contentType, err := storage.OpenObject(r.Context(), version.Storage, version.Archive)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "archive not available in storage")
+			return
+		}
+		defer body.Close()
+
+		// The key ends in the archive extension (<slug>/<version>.tar.gz).
+		filename := project.Slug + "-" + path.Base(version.Archive)
+		if contentType == "" {
+			contentType = mime.TypeByExtension(path.Ext(filename))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, body) //nolint:errcheck
+	}
+}
@@ -8,17 +8,19 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/ratelimit"
 	"github.com/romain325/doc-thor/server/services"
 	"github.com/romain325/doc-thor/server/vcs"
 	"gorm.io/gorm"
 )
 
-// RegisterWebhookRoutes registers webhook routes.
-func RegisterWebhookRoutes(r chi.Router, db *gorm.DB) {
-	r.Post("/api/v1/webhooks/{provider}/{slug}", handleWebhook(db))
+// RegisterWebhookRoutes registers webhook routes.  limiter caps the
+// deliveries each project accepts; nil leaves them unlimited.
+func RegisterWebhookRoutes(r chi.Router, db *gorm.DB, limiter *ratelimit.Limiter) {
+	r.Post("/api/v1/webhooks/{provider}/{slug}", handleWebhook(db, limiter))
 }
 
-func handleWebhook(db *gorm.DB) http.HandlerFunc {
+func handleWebhook(db *gorm.DB, limiter *ratelimit.Limiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		provider := chi.URLParam(r, "provider")
 		projectSlug := chi.URLParam(r, "slug")
@@ -66,6 +68,13 @@ func handleWebhook(db *gorm.DB) http.HandlerFunc {
 			return
 		}
 
+		// Only authenticated deliveries are charged, by project ID: forged
+		// ones cannot crowd out the real ones, nor made-up slugs add buckets.
+		if wait, ok := limiter.Allow(fmt.Sprint(project.ID)); !ok {
+			writeRateLimited(w, wait, "Too many webhook deliveries for this project")
+			return
+		}
+
 		// 6. Match event to branch mappings
 		var matchedMapping *models.BranchMapping
 		for i := range project.VCSConfig.BranchMappings {
@@ -94,7 +103,7 @@ func handleWebhook(db *gorm.DB) http.HandlerFunc {
 			ref = event.Tag
 		}
 
-		build, err := services.CreateBuild(db, project.ID, ref, versionTag)
+		build, err := services.CreateBuild(db, project.ID, ref, versionTag, event.Commit, services.TriggerWebhook)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "Failed to create build: "+err.Error())
 			return
@@ -0,0 +1,36 @@
+// Package scheduler runs the project build schedules inside the server
+// process.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/romain325/doc-thor/server/services"
+	"gorm.io/gorm"
+)
+
+// Run checks for due schedules every interval until ctx is cancelled.  The
+// first check happens immediately, so runs missed while the server was down
+// are handled at startup.  A run is considered missed (see
+// services.RunDueSchedules) when it is more than two intervals late.
+func Run(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := services.RunDueSchedules(db, time.Now(), 2*interval)
+		if err != nil {
+			log.Printf("scheduler: %v", err)
+		} else if n > 0 {
+			log.Printf("scheduler: queued %d build(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
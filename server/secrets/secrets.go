@@ -0,0 +1,287 @@
+// Package secrets encrypts values at rest with envelope encryption: every
+// value is sealed with its own random data key, and that data key is sealed
+// with the server's master key.  Moving to a new master key only re-seals
+// the data keys (see Rewrap).
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeySize is the required length of the master key in bytes (AES-256).
+const KeySize = 32
+
+// envelopePrefix starts every encrypted value:
+// v1:<key id>:base64(sealed data key):base64(sealed value).
+const envelopePrefix = "v1:"
+
+// A sealed data key is nonce || data key || tag, so every envelope carries
+// one of exactly this size; with the 8 hex digit key id it tells an
+// envelope from plaintext that merely starts with the prefix.
+const (
+	gcmOverhead    = 12 + 16
+	sealedKeySize  = KeySize + gcmOverhead
+	keyIDHexDigits = 8
+)
+
+var (
+	ErrNotConfigured = errors.New("secret encryption key is not configured")
+	ErrMalformed     = errors.New("malformed ciphertext")
+	ErrUnknownKey    = errors.New("value is encrypted with an unknown master key")
+)
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	current *masterKey
+	keys    = map[string]*masterKey{} // every installed key, by id
+	mu      sync.RWMutex
+)
+
+// SetKey installs the master key used by Encrypt.
+// Should be called once during application initialization.  Keys installed
+// before it stay available to Decrypt and Rewrap, which is how the rotation
+// command moves values from the old key to the new one.
+func SetKey(key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("secret key must be %d bytes, got %d", KeySize, len(key))
+	}
+	gcm, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(key)
+	k := &masterKey{id: hex.EncodeToString(sum[:4]), aead: gcm}
+
+	mu.Lock()
+	defer mu.Unlock()
+	keys[k.id] = k
+	current = k
+	return nil
+}
+
+// ParseKey decodes a base64-encoded master key as found in SECRET_KEY.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret key is not valid base64: %w", err)
+	}
+	return key, nil
+}
+
+// LoadKey returns the master key given either inline (SECRET_KEY) or as
+// the path of a file holding it (SECRET_KEY_FILE), or nil if neither is set.
+func LoadKey(encoded, path string) ([]byte, error) {
+	switch {
+	case encoded != "" && path != "":
+		return nil, errors.New("set only one of SECRET_KEY and SECRET_KEY_FILE")
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file: %w", err)
+		}
+		return ParseKey(string(data))
+	case encoded != "":
+		return ParseKey(encoded)
+	}
+	return nil, nil
+}
+
+// Enabled reports whether a master key has been installed.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// IsEncrypted reports whether s is a value produced by Encrypt.  Only the
+// shape is checked, not whether any installed key opens it.
+func IsEncrypted(s string) bool {
+	_, _, _, ok := splitEnvelope(s)
+	return ok
+}
+
+// Encrypt seals plaintext with a fresh AES-GCM data key and seals that key
+// with the master key.
+func Encrypt(plaintext string) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return "", ErrNotConfigured
+	}
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return envelope(current, dataKey, body)
+}
+
+// Decrypt reverses Encrypt.  It also opens values sealed directly with the
+// master key, as secret variables were before envelope encryption.
+func Decrypt(encoded string) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return "", ErrNotConfigured
+	}
+	if !IsEncrypted(encoded) {
+		plaintext, err := openLegacy(encoded)
+		return string(plaintext), err
+	}
+	_, dataKey, body, err := parseEnvelope(encoded)
+	if err != nil {
+		return "", err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, body, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap moves an encrypted value to the current master key.  Only its data
+// key is re-sealed; a value sealed directly with the master key is
+// re-encrypted instead.  A value already under the current key is returned
+// unchanged.
+func Rewrap(encoded string) (string, error) {
+	if !IsEncrypted(encoded) {
+		plaintext, err := Decrypt(encoded)
+		if err != nil {
+			return "", err
+		}
+		return Encrypt(plaintext)
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return "", ErrNotConfigured
+	}
+	k, dataKey, body, err := parseEnvelope(encoded)
+	if err != nil {
+		return "", err
+	}
+	if k == current {
+		return encoded, nil
+	}
+	return envelope(current, dataKey, body)
+}
+
+func envelope(k *masterKey, dataKey, body []byte) (string, error) {
+	wrapped, err := seal(k.aead, dataKey, []byte(k.id))
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + k.id + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(body), nil
+}
+
+// splitEnvelope returns the key id, sealed data key and sealed value of an
+// encrypted value, or ok false if s is not shaped like one.
+func splitEnvelope(s string) (id string, wrapped, body []byte, ok bool) {
+	if !strings.HasPrefix(s, envelopePrefix) {
+		return "", nil, nil, false
+	}
+	parts := strings.Split(strings.TrimPrefix(s, envelopePrefix), ":")
+	if len(parts) != 3 || len(parts[0]) != keyIDHexDigits {
+		return "", nil, nil, false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return "", nil, nil, false
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(wrapped) != sealedKeySize {
+		return "", nil, nil, false
+	}
+	body, err = base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(body) < gcmOverhead {
+		return "", nil, nil, false
+	}
+	return parts[0], wrapped, body, true
+}
+
+// parseEnvelope splits an encrypted value and unseals its data key.
+// The caller holds mu.
+func parseEnvelope(encoded string) (*masterKey, []byte, []byte, error) {
+	id, wrapped, body, ok := splitEnvelope(encoded)
+	if !ok {
+		return nil, nil, nil, ErrMalformed
+	}
+	k, ok := keys[id]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w (id %s)", ErrUnknownKey, id)
+	}
+	dataKey, err := open(k.aead, wrapped, []byte(k.id))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decrypt data key: %w", err)
+	}
+	return k, dataKey, body, nil
+}
+
+// openLegacy opens base64(nonce || ciphertext) sealed directly with a
+// master key.  The caller holds mu.
+func openLegacy(encoded string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	err = ErrUnknownKey
+	for _, k := range keys {
+		var plaintext []byte
+		if plaintext, err = open(k.aead, data, nil); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, fmt.Errorf("decrypt: %w", err)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
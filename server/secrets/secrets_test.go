@@ -0,0 +1,183 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useKeys replaces the installed master keys with ks, the last one
+// current, for the rest of the test.
+func useKeys(t *testing.T, ks ...[]byte) {
+	t.Helper()
+	mu.Lock()
+	savedKeys, savedCurrent := keys, current
+	keys, current = map[string]*masterKey{}, nil
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		keys, current = savedKeys, savedCurrent
+		mu.Unlock()
+	})
+	for _, k := range ks {
+		if err := SetKey(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestRoundTrip(t *testing.T) {
+	useKeys(t, testKey(1))
+	for _, plaintext := range []string{"", "glpat-secret", "v1:not:an:envelope", strings.Repeat("x", 4096)} {
+		enc, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q): %v", plaintext, err)
+		}
+		if !IsEncrypted(enc) {
+			t.Errorf("IsEncrypted(Encrypt(%q)) = false", plaintext)
+		}
+		dec, err := Decrypt(enc)
+		if err != nil || dec != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plaintext, dec, err)
+		}
+	}
+
+	a, _ := Encrypt("same")
+	b, _ := Encrypt("same")
+	if a == b {
+		t.Error("two encryptions of one value are identical")
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	useKeys(t, testKey(1))
+	enc, err := Encrypt("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(enc, ":")
+	shortKey := base64.StdEncoding.EncodeToString(make([]byte, sealedKeySize-1))
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{enc, true},
+		{"", false},
+		{"glpat-secret", false},
+		{"v1:", false},
+		{"v1:plaintext token", false},
+		{"v1:a:b:c", false},
+		{"v1:" + parts[1] + ":" + parts[2], false},
+		{"v1:zzzzzzzz:" + parts[2] + ":" + parts[3], false},
+		{"v1:" + parts[1] + ":" + shortKey + ":" + parts[3], false},
+		{"v1:" + parts[1] + ":" + parts[2] + ":" + parts[3] + ":extra", false},
+		{"v2:" + strings.Join(parts[1:], ":"), false},
+	}
+	for _, tt := range tests {
+		if got := IsEncrypted(tt.value); got != tt.want {
+			t.Errorf("IsEncrypted(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	useKeys(t, testKey(1))
+	enc, err := Encrypt("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(enc, ":")
+	body, _ := base64.StdEncoding.DecodeString(parts[3])
+	body[len(body)-1] ^= 1
+	parts[3] = base64.StdEncoding.EncodeToString(body)
+	if _, err := Decrypt(strings.Join(parts, ":")); err == nil {
+		t.Error("tampered value decrypted")
+	}
+
+	useKeys(t, testKey(2))
+	if _, err := Decrypt(enc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("other key: err = %v, want ErrUnknownKey", err)
+	}
+
+	useKeys(t)
+	if _, err := Encrypt("value"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Encrypt without a key: err = %v, want ErrNotConfigured", err)
+	}
+	if _, err := Decrypt(enc); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Decrypt without a key: err = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	useKeys(t, testKey(1))
+	enc, err := Encrypt("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Rewrap(enc); err != nil || got != enc {
+		t.Errorf("Rewrap under the current key = %q, %v; want it unchanged", got, err)
+	}
+
+	// Rotation: the old key stays installed, the new one is current.
+	useKeys(t, testKey(1), testKey(2))
+	moved, err := Rewrap(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved == enc || strings.Split(moved, ":")[3] != strings.Split(enc, ":")[3] {
+		t.Error("Rewrap did not re-seal only the data key")
+	}
+
+	useKeys(t, testKey(2))
+	if dec, err := Decrypt(moved); err != nil || dec != "value" {
+		t.Errorf("Decrypt after rotation = %q, %v", dec, err)
+	}
+}
+
+func TestLegacyValues(t *testing.T) {
+	useKeys(t, testKey(1))
+	mu.RLock()
+	sealed, err := seal(current.aead, []byte("old secret"), nil)
+	mu.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := base64.StdEncoding.EncodeToString(sealed)
+	if IsEncrypted(legacy) {
+		t.Error("legacy value taken for an envelope")
+	}
+	if dec, err := Decrypt(legacy); err != nil || dec != "old secret" {
+		t.Errorf("Decrypt(legacy) = %q, %v", dec, err)
+	}
+	moved, err := Rewrap(legacy)
+	if err != nil || !IsEncrypted(moved) {
+		t.Fatalf("Rewrap(legacy) = %q, %v", moved, err)
+	}
+	if dec, err := Decrypt(moved); err != nil || dec != "old secret" {
+		t.Errorf("Decrypt(Rewrap(legacy)) = %q, %v", dec, err)
+	}
+}
+
+func TestSerializerWithoutKey(t *testing.T) {
+	useKeys(t)
+	got, err := Serializer{}.Value(context.Background(), nil, reflect.Value{}, "glpat-secret")
+	if err != nil || got != "glpat-secret" {
+		t.Errorf("Value without a key = %v, %v; want the plaintext", got, err)
+	}
+
+	useKeys(t, testKey(1))
+	got, err = Serializer{}.Value(context.Background(), nil, reflect.Value{}, "glpat-secret")
+	if s, _ := got.(string); err != nil || !IsEncrypted(s) {
+		t.Errorf("Value with a key = %v, %v; want an envelope", got, err)
+	}
+}
@@ -0,0 +1,48 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// Serializer is a GORM serializer that keeps a string field encrypted in
+// the database and plain in memory:
+//
+//	AccessToken string `gorm:"serializer:secret"`
+//
+// Empty strings are stored as they are, and so is everything while no master
+// key is installed, as before fields were encrypted.  Plaintext is read back
+// unchanged until the startup migration (services.MigrateSecrets) encrypts
+// it.
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var s string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("secret field %s: unsupported value %T", field.Name, dbValue)
+	}
+	if IsEncrypted(s) {
+		var err error
+		if s, err = Decrypt(s); err != nil {
+			return fmt.Errorf("secret field %s: %w", field.Name, err)
+		}
+	}
+	return field.Set(ctx, dst, s)
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	s, _ := fieldValue.(string)
+	if s == "" || !Enabled() {
+		return s, nil
+	}
+	return Encrypt(s)
+}
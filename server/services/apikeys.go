@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+var knownScopes = []string{models.ScopeBuildsTrigger, models.ScopeVersionsPublish, models.ScopeProjectsWrite}
+
+// APIKeyRequest describes a key to create.  Empty Scopes give the key all
+// of its user's permissions; an empty Project leaves it unrestricted; 0
+// ExpiresInDays never expires.
+type APIKeyRequest struct {
+	Label         string   `json:"label"`
+	Scopes        []string `json:"scopes"`
+	Project       string   `json:"project"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// CreateAPIKey stores a new API key for a user under tokenHash.
+func CreateAPIKey(db *gorm.DB, userID uint, req APIKeyRequest, tokenHash string) (*models.Token, error) {
+	for _, s := range req.Scopes {
+		if !slices.Contains(knownScopes, s) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, s)
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, ErrInvalidExpiry
+	}
+
+	t := &models.Token{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		Type:      "apikey",
+		Label:     req.Label,
+		Scopes:    req.Scopes,
+	}
+	if req.Project != "" {
+		p, err := GetProject(db, req.Project)
+		if err != nil {
+			return nil, err
+		}
+		t.ProjectID, t.Project = &p.ID, p.Slug
+	}
+	if req.ExpiresInDays > 0 {
+		exp := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		t.ExpiresAt = &exp
+	}
+	if err := db.Create(t).Error; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListAPIKeys returns a user's API keys, newest first.  Expired keys are
+// listed until they are next presented, which deletes them.
+func ListAPIKeys(db *gorm.DB, userID uint) ([]models.Token, error) {
+	var keys []models.Token
+	if err := db.Where("user_id = ? AND type = ?", userID, "apikey").Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	slugs := map[uint]string{}
+	for i := range keys {
+		k := &keys[i]
+		if k.ProjectID == nil {
+			continue
+		}
+		if _, ok := slugs[*k.ProjectID]; !ok {
+			var p models.Project
+			db.Select("slug").Limit(1).Find(&p, *k.ProjectID)
+			slugs[*k.ProjectID] = p.Slug
+		}
+		k.Project = slugs[*k.ProjectID]
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes one of a user's API keys.  Returns ErrNotFound when
+// the user has no such key.
+func RevokeAPIKey(db *gorm.DB, userID, id uint) error {
+	res := db.Where("id = ? AND user_id = ? AND type = ?", id, userID, "apikey").Delete(&models.Token{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+// AuditFilter selects audit events.  Empty fields match everything.
+type AuditFilter struct {
+	Actor string
+	// Action matches exactly, or as a prefix: "version" matches
+	// "version.update".
+	Action string
+	// Target matches the resource and everything under it: "projects/docs"
+	// matches "projects/docs/versions/1.2.0".
+	Target string
+	Since  time.Time
+	Until  time.Time
+	// Failed keeps only refused and failed attempts.
+	Failed bool
+	Limit  int
+	Offset int
+}
+
+// ProtectAuditLog makes the audit table append-only: the database refuses
+// to update or delete its rows.
+func ProtectAuditLog(db *gorm.DB) error {
+	for _, op := range []string{"UPDATE", "DELETE"} {
+		err := db.Exec(`CREATE TRIGGER IF NOT EXISTS audit_events_no_` + op + `
+			BEFORE ` + op + ` ON audit_events
+			BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordAuditEvent appends an event to the audit log.
+func RecordAuditEvent(db *gorm.DB, e *models.AuditEvent) error {
+	return db.Create(e).Error
+}
+
+// ListAuditEvents returns the events matching f, newest first.
+func ListAuditEvents(db *gorm.DB, f AuditFilter) ([]models.AuditEvent, error) {
+	q := db.Model(&models.AuditEvent{})
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		q = q.Where(`action = ? OR action LIKE ? ESCAPE '\'`, f.Action, likeEscape(f.Action)+".%")
+	}
+	if f.Target != "" {
+		q = q.Where(`target = ? OR target LIKE ? ESCAPE '\'`, f.Target, likeEscape(f.Target)+"/%")
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.Failed {
+		q = q.Where("status >= ?", 400)
+	}
+	var events []models.AuditEvent
+	err := q.Order("id DESC").Limit(f.Limit).Offset(f.Offset).Find(&events).Error
+	return events, err
+}
+
+// likeEscaper makes a string match itself literally in a LIKE pattern with
+// ESCAPE '\': slugs may contain "_", which LIKE reads as any character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func likeEscape(s string) string { return likeEscaper.Replace(s) }
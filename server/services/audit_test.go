@@ -0,0 +1,53 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/romain325/doc-thor/server/models"
+)
+
+func TestListAuditEventsMatchesLiterally(t *testing.T) {
+	db := openTestDB(t, &models.AuditEvent{})
+	if err := ProtectAuditLog(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []models.AuditEvent{
+		{Actor: "alice", Action: "project.update", Target: "projects/my_docs"},
+		{Actor: "alice", Action: "version.update", Target: "projects/my_docs/versions/1.0"},
+		{Actor: "alice", Action: "project.update", Target: "projects/myxdocs"},
+		{Actor: "alice", Action: "version.update", Target: "projects/myxdocs/versions/1.0"},
+		{Actor: "alice", Action: "project.update", Target: "projects/100%"},
+		{Actor: "alice", Action: "project.update", Target: "projects/1000"},
+		{Actor: "alice", Action: "project_x.update", Target: "projects/a"},
+	} {
+		if err := RecordAuditEvent(db, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter AuditFilter
+		want   []string // targets, newest first
+	}{
+		{AuditFilter{Target: "projects/my_docs"}, []string{"projects/my_docs/versions/1.0", "projects/my_docs"}},
+		{AuditFilter{Target: "projects/myxdocs"}, []string{"projects/myxdocs/versions/1.0", "projects/myxdocs"}},
+		{AuditFilter{Target: "projects/100%"}, []string{"projects/100%"}},
+		{AuditFilter{Action: "project_x"}, []string{"projects/a"}},
+		{AuditFilter{Action: "projectxx"}, nil},
+	}
+	for _, tt := range tests {
+		tt.filter.Limit = 100
+		events, err := ListAuditEvents(db, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range events {
+			got = append(got, e.Target)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
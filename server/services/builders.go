@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+// RegisterBuilder records a builder under name with a new credential hash.
+// A builder registering again under a known name (a restarted agent) keeps
+// its record; the credential it held before stops working and the builds it
+// was running go back to the queue.  A name whose builder was heard from
+// within offlineAfter is refused with ErrBuilderOnline, so the registration
+// token alone cannot take over a working builder and its builds.  A name
+// removed under registrationHash, the hash of the registration token in use,
+// is refused with ErrBuilderRevoked.
+func RegisterBuilder(db *gorm.DB, name, version string, labels []string, tokenHash, registrationHash string, offlineAfter time.Duration) (*models.Builder, error) {
+	if !labelPattern.MatchString(name) {
+		return nil, ErrInvalidBuilderName
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+
+	var b models.Builder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var revoked models.RevokedBuilder
+		if err := tx.Where("name = ?", name).Limit(1).Find(&revoked).Error; err != nil {
+			return err
+		}
+		if revoked.Name != "" {
+			if revoked.RegistrationHash == registrationHash {
+				return ErrBuilderRevoked
+			}
+			// The token was rotated since: the revocation has done its job.
+			if err := tx.Delete(&revoked).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("name = ?", name).Limit(1).Find(&b).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if b.ID != 0 {
+			if b.LastSeenAt != nil && b.LastSeenAt.After(now.Add(-offlineAfter)) {
+				return ErrBuilderOnline
+			}
+			if err := requeueBuilds(tx, b.ID); err != nil {
+				return err
+			}
+		}
+		b.Name = name
+		b.TokenHash = tokenHash
+		b.Version = version
+		b.Labels = labels
+		b.LastSeenAt = &now
+		return tx.Save(&b).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBuilders returns the registered builders ordered by name.  A builder
+// counts as online when it was heard from within offlineAfter; its current
+// jobs are the running builds it claimed.
+func ListBuilders(db *gorm.DB, offlineAfter time.Duration) ([]models.Builder, error) {
+	var builders []models.Builder
+	if err := db.Order("name ASC").Find(&builders).Error; err != nil {
+		return nil, err
+	}
+
+	var running []models.Build
+	if err := db.Select("id", "builder_id").
+		Where("status = ? AND builder_id IS NOT NULL", "running").
+		Order("id ASC").Find(&running).Error; err != nil {
+		return nil, err
+	}
+	jobs := map[uint][]uint{}
+	for _, b := range running {
+		jobs[*b.BuilderID] = append(jobs[*b.BuilderID], b.ID)
+	}
+
+	cutoff := time.Now().Add(-offlineAfter)
+	for i := range builders {
+		b := &builders[i]
+		b.Online = b.LastSeenAt != nil && b.LastSeenAt.After(cutoff)
+		b.CurrentJobs = jobs[b.ID]
+		if b.CurrentJobs == nil {
+			b.CurrentJobs = []uint{}
+		}
+	}
+	return builders, nil
+}
+
+// DeleteBuilder removes a builder, revoking its credential.  The builds it
+// was running go back to the queue, since it can no longer report them;
+// finished builds keep their reference for the record.  Its name is revoked
+// under registrationHash, the hash of the registration token in use, so the
+// agent cannot register again until that token is rotated.
+func DeleteBuilder(db *gorm.DB, id uint, registrationHash string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var b models.Builder
+		if err := tx.First(&b, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&b).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&models.RevokedBuilder{Name: b.Name, RegistrationHash: registrationHash}).Error; err != nil {
+			return err
+		}
+		return requeueBuilds(tx, id)
+	})
+}
+
+// requeueBuilds puts the running builds claimed by a builder back in the
+// queue for another builder to claim.
+func requeueBuilds(tx *gorm.DB, builderID uint) error {
+	return tx.Model(&models.Build{}).
+		Where("builder_id = ? AND status = ?", builderID, "running").
+		Updates(map[string]any{"status": "pending", "started_at": nil, "builder_id": nil}).Error
+}
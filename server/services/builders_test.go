@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB returns a fresh SQLite database with the given tables.
+func openTestDB(t *testing.T, tables ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRemovedBuilderCannotRegisterAgain(t *testing.T) {
+	db := openTestDB(t, &models.Builder{}, &models.RevokedBuilder{}, &models.Build{})
+
+	b, err := RegisterBuilder(db, "b1", "1.0", nil, "cred-1", "reg-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteBuilder(db, b.ID, "reg-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteBuilder(db, b.ID, "reg-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := RegisterBuilder(db, "b1", "1.0", nil, "cred-2", "reg-1", 0); !errors.Is(err, ErrBuilderRevoked) {
+		t.Errorf("register after removal: err = %v, want ErrBuilderRevoked", err)
+	}
+	if _, err := RegisterBuilder(db, "b2", "1.0", nil, "cred-3", "reg-1", 0); err != nil {
+		t.Errorf("register under another name: %v", err)
+	}
+
+	// Rotating the registration token lifts the revocation.
+	if _, err := RegisterBuilder(db, "b1", "1.0", nil, "cred-4", "reg-2", time.Minute); err != nil {
+		t.Fatalf("register after rotation: %v", err)
+	}
+	var n int64
+	db.Model(&models.RevokedBuilder{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d revocations left after re-registering, want 0", n)
+	}
+}
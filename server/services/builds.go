@@ -9,12 +9,31 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-func CreateBuild(db *gorm.DB, projectID uint, ref, tag string) (*models.Build, error) {
+// What queued a build.  A build's priority follows from it: someone waiting
+// on a manual build beats a push, which beats a periodic rebuild.
+const (
+	TriggerManual   = "manual"
+	TriggerWebhook  = "webhook"
+	TriggerSchedule = "schedule"
+)
+
+var triggerPriority = map[string]int{
+	TriggerManual:   20,
+	TriggerWebhook:  10,
+	TriggerSchedule: 0,
+}
+
+// CreateBuild queues a pending build.  commit may be empty when the exact
+// revision is not known up front; the builder then builds the tip of ref.
+func CreateBuild(db *gorm.DB, projectID uint, ref, tag, commit, trigger string) (*models.Build, error) {
 	b := &models.Build{
 		ProjectID: projectID,
 		Ref:       ref,
 		Tag:       tag,
+		Commit:    commit,
 		Status:    "pending",
+		Trigger:   trigger,
+		Priority:  triggerPriority[trigger],
 	}
 	if err := db.Create(b).Error; err != nil {
 		return nil, err
@@ -22,22 +41,51 @@ func CreateBuild(db *gorm.DB, projectID uint, ref, tag string) (*models.Build, e
 	return b, nil
 }
 
-// ClaimPendingBuild atomically finds the oldest pending build, transitions it to
+// ClaimPendingBuild atomically picks the next pending build, transitions it to
 // running, and returns it together with its project.  Safe under SQLite's
 // single-writer constraint without explicit row locking.  Returns ErrNotFound
-// when the queue is empty.
-func ClaimPendingBuild(db *gorm.DB) (*models.Build, *models.Project, error) {
+// when nothing can be claimed.
+//
+// The highest priority wins.  Within a priority, projects take turns: the one
+// whose last build started longest ago goes first, so a project that queued
+// fifty tags does not hold everyone else back.  Within a project, the oldest
+// build goes first.  Projects that already have maxRunning builds running are
+// passed over; 0 means no limit.  So are projects requiring a label the
+// polling builder does not have.  The build is recorded against the builder,
+// which alone may report its result.
+func ClaimPendingBuild(db *gorm.DB, maxRunning int, builder *models.Builder) (*models.Build, *models.Project, error) {
 	var b models.Build
+	var p models.Project
 	err := db.Transaction(func(tx *gorm.DB) error {
 		// Silent logger: an empty queue is the normal idle state; letting GORM
 		// log ErrRecordNotFound every poll cycle is just noise.
 		quiet := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Silent)})
-		if err := quiet.Where("status = ?", "pending").Order("created_at ASC").First(&b).Error; err != nil {
+		q := quiet.Where("status = ?", "pending")
+		if maxRunning > 0 {
+			q = q.Where("(SELECT COUNT(*) FROM builds r WHERE r.project_id = builds.project_id AND r.status = ?) < ?", "running", maxRunning)
+		}
+		// Every label the project requires must be among the builder's.
+		// Required labels are a JSON list, hence json_each.
+		labels := "NOT EXISTS (SELECT 1 FROM projects p, json_each(p.required_labels) l WHERE p.id = builds.project_id AND l.type = 'text'"
+		if len(builder.Labels) > 0 {
+			q = q.Where(labels+" AND l.value NOT IN ?)", builder.Labels)
+		} else {
+			q = q.Where(labels + ")")
+		}
+		err := q.Order("priority DESC").
+			Order("(SELECT COALESCE(MAX(s.started_at), '') FROM builds s WHERE s.project_id = builds.project_id) ASC").
+			Order("created_at ASC").
+			First(&b).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&p, b.ProjectID).Error; err != nil {
 			return err
 		}
 		now := time.Now()
 		b.Status = "running"
 		b.StartedAt = &now
+		b.BuilderID = &builder.ID
 		return tx.Save(&b).Error
 	})
 	if err != nil {
@@ -46,18 +94,34 @@ func ClaimPendingBuild(db *gorm.DB) (*models.Build, *models.Project, error) {
 		}
 		return nil, nil, err
 	}
-
-	var p models.Project
-	if err := db.First(&p, b.ProjectID).Error; err != nil {
-		return nil, nil, err
-	}
 	return &b, &p, nil
 }
 
-// ReportBuildResult records the outcome reported by a builder.  Only builds
-// currently in "running" state may be finalised; any other status returns
-// ErrBuildNotRunning.
-func ReportBuildResult(db *gorm.DB, buildID uint, status, logs, errMsg string) (*models.Build, error) {
+// BuildOutput is where a builder stored a successful build's files.  It is
+// kept on the version the build creates.
+type BuildOutput struct {
+	Storage string `json:"storage"` // StorageS3 or StorageLocal; empty means StorageS3
+	Archive string `json:"archive"` // storage key of the downloadable archive, if any
+	// Files is the output manifest; builders that predate it send none.
+	Files []models.VersionFile `json:"files"`
+}
+
+// BuildReport is the outcome of a build as reported by a builder.
+type BuildReport struct {
+	Status   string   `json:"status"` // "success" or "failed"
+	Logs     string   `json:"logs"`
+	Error    string   `json:"error"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Stages is empty for builders that predate per-stage reporting, and
+	// for failures before the pipeline started.
+	Stages []models.BuildStage `json:"stages,omitempty"`
+	BuildOutput
+}
+
+// ReportBuildResult records the outcome reported by a builder.  Only the
+// builder that claimed the build may finalise it (ErrBuildNotClaimed), and
+// only while it is in "running" state (ErrBuildNotRunning).
+func ReportBuildResult(db *gorm.DB, logs LogStore, builderID, buildID uint, report BuildReport) (*models.Build, error) {
 	var b models.Build
 	if err := db.First(&b, buildID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -65,22 +129,55 @@ func ReportBuildResult(db *gorm.DB, buildID uint, status, logs, errMsg string) (
 		}
 		return nil, err
 	}
+	if b.BuilderID == nil || *b.BuilderID != builderID {
+		return nil, ErrBuildNotClaimed
+	}
 	if b.Status != "running" {
 		return nil, ErrBuildNotRunning
 	}
 
 	now := time.Now()
-	b.Status = status
-	b.Logs = logs
-	b.Error = errMsg
+	b.Status = report.Status
+	// The full log goes to the log store and only its tail to the database.
+	// Like masking, this is best-effort: if the file cannot be written the
+	// capped tail is all that is kept.
+	fullLog := maskSecrets(db, b.ProjectID, report.Logs)
+	if size, tail, err := logs.Save(b.ID, fullLog); err == nil {
+		b.Logs, b.LogSize = tail, size
+	} else {
+		b.Logs = logTail(capLog(fullLog, logs.MaxBytes), logs.TailBytes)
+	}
+	b.Error = maskSecrets(db, b.ProjectID, report.Error)
+	b.Warnings = nil
+	for _, w := range report.Warnings {
+		b.Warnings = append(b.Warnings, maskSecrets(db, b.ProjectID, w))
+	}
 	b.FinishedAt = &now
 	if err := db.Save(&b).Error; err != nil {
 		return nil, err
 	}
+	if len(report.Stages) > 0 {
+		stages := make([]models.BuildStage, len(report.Stages))
+		for i, st := range report.Stages {
+			stages[i] = models.BuildStage{
+				BuildID:    b.ID,
+				Position:   i,
+				Name:       st.Name,
+				Status:     st.Status,
+				StartedAt:  st.StartedAt,
+				FinishedAt: st.FinishedAt,
+				Error:      maskSecrets(db, b.ProjectID, st.Error),
+			}
+		}
+		if err := db.Create(&stages).Error; err != nil {
+			return nil, err
+		}
+		b.Stages = stages
+	}
 
 	// Successful build with a tag → publish the version immediately.
-	if status == "success" && b.Tag != "" {
-		if _, err := CreateVersion(db, b.ProjectID, b.ID, b.Tag); err != nil {
+	if report.Status == "success" && b.Tag != "" {
+		if _, err := CreateVersion(db, b.ProjectID, b.ID, b.Tag, report.BuildOutput); err != nil {
 			return nil, err
 		}
 	}
@@ -99,7 +196,9 @@ func ListBuilds(db *gorm.DB, projectID uint, limit, offset int) ([]models.Build,
 
 func GetBuild(db *gorm.DB, projectID, buildID uint) (*models.Build, error) {
 	var b models.Build
-	if err := db.Where("id = ? AND project_id = ?", buildID, projectID).First(&b).Error; err != nil {
+	err := db.Preload("Stages", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND project_id = ?", buildID, projectID).First(&b).Error
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
 		}
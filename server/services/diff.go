@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+// Bounds on the work one diff request may cause.  Pages past maxDiffPages are
+// still listed as modified, without a text diff.
+const (
+	maxDiffPages     = 100
+	maxDiffPageBytes = 2 << 20
+	maxDiffCells     = 4 << 20 // lines(from) * lines(to) compared per page
+	diffContext      = 3
+)
+
+// VersionDiff lists the pages that differ between two versions of a project.
+// Only HTML pages are compared; assets show up through the pages that use
+// them.
+type VersionDiff struct {
+	From     string     `json:"from"`
+	To       string     `json:"to"`
+	Added    []string   `json:"added"`
+	Removed  []string   `json:"removed"`
+	Modified []PageDiff `json:"modified"`
+	// Truncated is set when more pages were modified than were diffed.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// PageDiff is a unified diff of the text content of one page.  Error is set
+// instead when either copy of the page could not be read from storage.
+type PageDiff struct {
+	Path  string `json:"path"`
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// DiffVersions compares the manifests of versions from and to, then diffs the
+// text of every page whose content hash changed.  Pages whose markup changed
+// but whose text did not (e.g. a new asset hash in a <link>) are left out.
+// Both versions need a manifest; ErrNoManifest is returned otherwise.
+func DiffVersions(ctx context.Context, db *gorm.DB, storage StorageLocations, project *models.Project, from, to string) (*VersionDiff, error) {
+	a, aFiles, err := versionPages(db, project.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, bFiles, err := versionPages(db, project.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &VersionDiff{From: from, To: to, Added: []string{}, Removed: []string{}, Modified: []PageDiff{}}
+	for path := range aFiles {
+		if _, ok := bFiles[path]; !ok {
+			d.Removed = append(d.Removed, path)
+		}
+	}
+	var changed []string
+	for path, f := range bFiles {
+		old, ok := aFiles[path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, path)
+		case old.SHA256 != f.SHA256:
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(d.Removed)
+	sort.Strings(d.Added)
+	sort.Strings(changed)
+
+	for i, path := range changed {
+		if i >= maxDiffPages {
+			d.Truncated = true
+			d.Modified = append(d.Modified, PageDiff{Path: path})
+			continue
+		}
+		oldText, err := pageText(ctx, storage, a, project.Slug, path)
+		if err != nil {
+			d.Modified = append(d.Modified, PageDiff{Path: path, Error: err.Error()})
+			continue
+		}
+		newText, err := pageText(ctx, storage, b, project.Slug, path)
+		if err != nil {
+			d.Modified = append(d.Modified, PageDiff{Path: path, Error: err.Error()})
+			continue
+		}
+		if diff := unifiedDiff(from+"/"+path, to+"/"+path, oldText, newText); diff != "" {
+			d.Modified = append(d.Modified, PageDiff{Path: path, Diff: diff})
+		}
+	}
+	return d, nil
+}
+
+// versionPages loads a version and the HTML pages of its manifest, by path.
+func versionPages(db *gorm.DB, projectID uint, tag string) (*models.Version, map[string]models.VersionFile, error) {
+	v, err := GetVersion(db, projectID, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := ListVersionFiles(db, v.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoManifest, tag)
+	}
+	pages := map[string]models.VersionFile{}
+	for _, f := range files {
+		if strings.HasPrefix(f.ContentType, "text/html") {
+			pages[f.Path] = f
+		}
+	}
+	return v, pages, nil
+}
+
+// pageText reads a page of v from storage and returns its visible text, one
+// block per line.
+func pageText(ctx context.Context, storage StorageLocations, v *models.Version, slug, path string) ([]string, error) {
+	body, _, err := storage.OpenObject(ctx, v.Storage, slug+"/"+v.Tag+"/"+path)
+	if err != nil {
+		return nil, fmt.Errorf("%s not available in storage", v.Tag)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxDiffPageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", v.Tag, err)
+	}
+	return extractText(string(data)), nil
+}
+
+var (
+	htmlComment  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlHidden   = regexp.MustCompile(`(?is)<(script|style|noscript|template)\b.*?</(script|style|noscript|template)\s*>`)
+	htmlBlockTag = regexp.MustCompile(`(?i)</?(address|article|aside|blockquote|br|dd|div|dl|dt|figcaption|footer|h[1-6]|header|hr|li|main|nav|ol|p|pre|section|table|td|th|title|tr|ul)\b[^>]*>`)
+	htmlTag      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// extractText strips markup from an HTML page, keeping one line per block
+// element with whitespace collapsed.  It is deliberately crude: the result is
+// only ever compared with the same extraction of another build.
+func extractText(page string) []string {
+	page = htmlComment.ReplaceAllString(page, "")
+	page = htmlHidden.ReplaceAllString(page, "")
+	page = htmlBlockTag.ReplaceAllString(page, "\n")
+	page = htmlTag.ReplaceAllString(page, "")
+	page = html.UnescapeString(page)
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff renders the line diff of a and b in unified format, or "" when
+// they are equal.
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	ops := editScript(a, b)
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// aPos[i]/bPos[i] are the 0-based lines of a and b before ops[i].
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.kind != '+' {
+			aPos[i+1]++
+		}
+		if op.kind != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk until diffContext*2 unchanged lines separate it from
+		// the next change.
+		start := max(0, i-diffContext)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(run, end+diffContext)
+				break
+			}
+			end = run
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[end]), hunkRange(bPos[start], bPos[end]))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// editScript computes a shortest line edit script from a to b using the
+// longest common subsequence.  Inputs too large to compare line by line are
+// reported as fully replaced.
+func editScript(a, b []string) []diffOp {
+	// Common prefix and suffix are cheap to strip and usually most of a page.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:pre] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, lcsScript(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+func lcsScript(a, b []string) []diffOp {
+	var ops []diffOp
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
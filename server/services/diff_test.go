@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/romain325/doc-thor/server/models"
+)
+
+// numbered returns n lines "line 1" to "line n".
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return lines
+}
+
+// with returns a copy of lines with the 1-based lines in edits replaced.
+func with(lines []string, edits map[int]string) []string {
+	out := append([]string(nil), lines...)
+	for n, text := range edits {
+		out[n-1] = text
+	}
+	return out
+}
+
+// hunkHeaders returns the "@@" lines of a unified diff.
+func hunkHeaders(diff string) []string {
+	var headers []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			headers = append(headers, line)
+		}
+	}
+	return headers
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		headers []string
+		body    string // the whole diff, when given
+	}{
+		{
+			name: "identical",
+			a:    numbered(10),
+			b:    numbered(10),
+		},
+		{
+			name:    "one line changed",
+			a:       numbered(10),
+			b:       with(numbered(10), map[int]string{5: "LINE 5"}),
+			headers: []string{"@@ -2,7 +2,7 @@"},
+			body: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n" +
+				" line 2\n line 3\n line 4\n-line 5\n+LINE 5\n line 6\n line 7\n line 8\n",
+		},
+		{
+			name:    "change at the start",
+			a:       numbered(10),
+			b:       with(numbered(10), map[int]string{1: "LINE 1"}),
+			headers: []string{"@@ -1,4 +1,4 @@"},
+		},
+		{
+			name:    "distant changes make separate hunks",
+			a:       numbered(30),
+			b:       with(numbered(30), map[int]string{2: "LINE 2", 26: "LINE 26"}),
+			headers: []string{"@@ -1,5 +1,5 @@", "@@ -23,7 +23,7 @@"},
+		},
+		{
+			name:    "close changes share a hunk",
+			a:       numbered(30),
+			b:       with(numbered(30), map[int]string{10: "LINE 10", 16: "LINE 16"}),
+			headers: []string{"@@ -7,13 +7,13 @@"},
+		},
+		{
+			name:    "lines added to an empty page",
+			a:       nil,
+			b:       []string{"new"},
+			headers: []string{"@@ -0,0 +1,1 @@"},
+			body:    "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n",
+		},
+		{
+			name:    "lines removed",
+			a:       numbered(5),
+			b:       numbered(3),
+			headers: []string{"@@ -1,5 +1,3 @@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := unifiedDiff("a", "b", tt.a, tt.b)
+			if got := hunkHeaders(diff); !reflect.DeepEqual(got, tt.headers) {
+				t.Errorf("hunks %q, want %q\n%s", got, tt.headers, diff)
+			}
+			if tt.headers == nil && diff != "" {
+				t.Errorf("diff of equal pages = %q, want empty", diff)
+			}
+			if tt.body != "" && diff != tt.body {
+				t.Errorf("diff =\n%s\nwant\n%s", diff, tt.body)
+			}
+		})
+	}
+}
+
+func TestEditScriptFallsBackWhenTooLarge(t *testing.T) {
+	// Same middle, different ends: nothing to strip, and far more than
+	// maxDiffCells to compare, so every line is replaced.
+	a := numbered(3000)
+	b := with(a, map[int]string{1: "first", 3000: "last"})
+	if len(a)*len(b) <= maxDiffCells {
+		t.Fatal("inputs too small to exceed maxDiffCells")
+	}
+	ops := editScript(a, b)
+	if len(ops) != len(a)+len(b) {
+		t.Fatalf("%d ops, want %d", len(ops), len(a)+len(b))
+	}
+	for i, op := range ops {
+		want := byte('-')
+		if i >= len(a) {
+			want = '+'
+		}
+		if op.kind != want {
+			t.Fatalf("op %d is %q, want %q", i, op.kind, want)
+		}
+	}
+
+	// Under the bound, the common lines are found.
+	small := editScript(numbered(100), with(numbered(100), map[int]string{1: "first", 100: "last"}))
+	if len(small) != 102 {
+		t.Errorf("%d ops for a small input, want 102", len(small))
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	page := `<html><head><title>Guide</title><style>p { color: red }</style></head>
+<body><!-- nav --><script>var x = "<p>";</script>
+<h1>Getting   started</h1><p>Install the <code>cli</code> &amp; run it.</p>
+<ul><li>one</li><li>two</li></ul></body></html>`
+	want := []string{"Guide", "Getting started", "Install the cli & run it.", "one", "two"}
+	if got := extractText(page); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractText = %q, want %q", got, want)
+	}
+}
+
+func TestDiffVersionsTruncates(t *testing.T) {
+	db := openTestDB(t, &models.Project{}, &models.Version{}, &models.VersionFile{})
+	dir := t.TempDir()
+	project := &models.Project{Name: "Docs", Slug: "docs"}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	pages := maxDiffPages + 2
+	for _, tag := range []string{"1.0", "2.0"} {
+		v := &models.Version{ProjectID: project.ID, BuildID: 1, Tag: tag, Storage: StorageLocal}
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < pages; i++ {
+			path := fmt.Sprintf("page%03d.html", i)
+			body := fmt.Sprintf("<p>page %d of %s</p>", i, tag)
+			file := filepath.Join(dir, "docs", tag, path)
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f := models.VersionFile{VersionID: v.ID, Path: path, SHA256: tag, ContentType: "text/html; charset=utf-8"}
+			if err := db.Create(&f).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	d, err := DiffVersions(context.Background(), db, StorageLocations{LocalDir: dir}, project, "1.0", "2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Truncated {
+		t.Error("Truncated not set")
+	}
+	if len(d.Modified) != pages {
+		t.Fatalf("%d modified pages, want %d", len(d.Modified), pages)
+	}
+	for i, p := range d.Modified {
+		if diffed := p.Diff != ""; diffed != (i < maxDiffPages) {
+			t.Errorf("page %d (%s): diffed = %v", i, p.Path, diffed)
+		}
+	}
+}
@@ -55,19 +55,36 @@ type ImportProjectRequest struct {
 }
 
 // ImportProject creates a project from a discovered project and optionally registers a webhook.
-func ImportProject(ctx context.Context, db *gorm.DB, req ImportProjectRequest) (*models.Project, error) {
+func ImportProject(ctx context.Context, db *gorm.DB, policy ImagePolicy, req ImportProjectRequest) (*models.Project, error) {
 	if req.DiscoveredProject.DocThorConfig == nil {
 		return nil, fmt.Errorf("discovered project has no doc-thor config")
 	}
 
 	config := req.DiscoveredProject.DocThorConfig
 
+	// Check the image before touching the VCS: a rejected import must not
+	// leave a webhook behind.
+	if err := policy.Check(config.DockerImage); err != nil {
+		return nil, err
+	}
+	if err := ValidateLabels(config.RequiredLabels); err != nil {
+		return nil, err
+	}
+	if config.Visibility != "" {
+		if err := ValidateVisibility(config.Visibility); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create project
 	project := &models.Project{
-		Slug:        config.Slug,
-		Name:        config.Name,
-		SourceURL:   req.DiscoveredProject.CloneURL,
-		DockerImage: config.DockerImage,
+		Slug:           config.Slug,
+		Name:           config.Name,
+		SourceURL:      req.DiscoveredProject.CloneURL,
+		DockerImage:    config.DockerImage,
+		BuildTimeout:   config.BuildTimeout,
+		RequiredLabels: config.RequiredLabels,
+		Visibility:     config.Visibility,
 	}
 
 	// Use branch mappings from request, or fall back to config file
@@ -135,7 +152,7 @@ func ImportProject(ctx context.Context, db *gorm.DB, req ImportProjectRequest) (
 	}
 
 	// Create project in database
-	if err := CreateProject(db, project); err != nil {
+	if err := CreateProject(db, policy, project); err != nil {
 		// If project creation fails and webhook was registered, try to clean up
 		if req.RegisterWebhook && project.VCSConfig != nil {
 			integration, _ := GetVCSIntegration(db, req.IntegrationName)
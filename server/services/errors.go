@@ -6,4 +6,24 @@ var (
 	ErrNotFound        = errors.New("not found")
 	ErrAlreadyExists   = errors.New("already exists")
 	ErrBuildNotRunning = errors.New("build is not in running state")
+	ErrBuildNotClaimed = errors.New("build was not claimed by this builder")
+
+	ErrInvalidVariableKey  = errors.New("variable key must match [A-Za-z_][A-Za-z0-9_]*")
+	ErrReservedVariableKey = errors.New("variable keys starting with DOCTHOR_ are reserved")
+	ErrInvalidResources    = errors.New("resource limits must not be negative")
+	ErrImageNotAllowed     = errors.New("docker image not allowed")
+	ErrInvalidBuildTimeout = errors.New("build timeout must not be negative")
+	ErrNoManifest          = errors.New("version has no file manifest")
+	ErrInvalidCron         = errors.New("invalid cron expression")
+	ErrInvalidLabel        = errors.New("labels must match [A-Za-z0-9][A-Za-z0-9._-]*")
+	ErrInvalidBuilderName  = errors.New("builder name must match [A-Za-z0-9][A-Za-z0-9._-]*")
+	ErrBuilderOnline       = errors.New("a builder with this name is online")
+	ErrBuilderRevoked      = errors.New("a builder with this name was removed; rotate the registration token to register it again")
+	ErrInvalidRole         = errors.New("role must be viewer, maintainer, or admin")
+	ErrLastAdmin           = errors.New("cannot remove the last admin")
+	ErrInvalidScope        = errors.New("scopes must be builds:trigger, versions:publish, or projects:write")
+	ErrInvalidExpiry       = errors.New("expiry must not be negative")
+	ErrInvalidUsername     = errors.New("username must match [A-Za-z0-9][A-Za-z0-9._@-]*")
+	ErrWeakPassword        = errors.New("password must be at least 8 characters")
+	ErrInvalidVisibility   = errors.New("visibility must be public, internal, or private")
 )
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ImagePolicy restricts which builder images projects may use.  The zero
+// value allows any image.
+type ImagePolicy struct {
+	// AllowedPatterns are glob patterns matched against the normalized
+	// repository name (registry/path, without tag or digest), e.g.
+	// "docker.io/doc-thor/*" or "registry.example.com/docs/**".  "*" matches
+	// within one path segment; a trailing "/**" matches any depth.  Patterns
+	// are normalized like images, so "doc-thor/*" means Docker Hub.
+	// Empty means every repository is allowed.
+	AllowedPatterns []string
+	// RequireDigest rejects images that are not pinned by @sha256 digest.
+	RequireDigest bool
+}
+
+// Check returns ErrImageNotAllowed (wrapped with the reason) if image
+// violates the policy.
+func (p ImagePolicy) Check(image string) error {
+	if image == "" {
+		return nil
+	}
+	repo, digest := splitImageRef(image)
+	if p.RequireDigest && !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("%w: %s must be pinned by digest (image@sha256:...)", ErrImageNotAllowed, image)
+	}
+	if len(p.AllowedPatterns) == 0 {
+		return nil
+	}
+	for _, pattern := range p.AllowedPatterns {
+		if matchImagePattern(pattern, repo) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s does not match any allowed registry or repository", ErrImageNotAllowed, image)
+}
+
+// splitImageRef returns the normalized repository name of an image reference
+// and its digest (empty when not pinned).
+func splitImageRef(ref string) (repo, digest string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, digest = ref[:i], ref[i+1:]
+	}
+	// A tag is a ":" after the last "/"; an earlier ":" is a registry port.
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return normalizeRepo(ref), digest
+}
+
+// normalizeRepo expands Docker Hub shorthands the way the Docker CLI does:
+// "mkdocs" → "docker.io/library/mkdocs", "org/img" → "docker.io/org/img".
+func normalizeRepo(name string) string {
+	first, _, found := strings.Cut(name, "/")
+	if found && isRegistryHost(first) {
+		return name
+	}
+	if !found {
+		return "docker.io/library/" + name
+	}
+	return "docker.io/" + name
+}
+
+func isRegistryHost(s string) bool {
+	return strings.ContainsAny(s, ".:") || s == "localhost"
+}
+
+func matchImagePattern(pattern, repo string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		// A bare prefix is either a registry ("ghcr.io/**") or a Docker Hub
+		// namespace ("doc-thor/**"); it never means an official image.
+		first, _, _ := strings.Cut(prefix, "/")
+		if !isRegistryHost(first) {
+			prefix = "docker.io/" + prefix
+		}
+		return strings.HasPrefix(repo, prefix+"/")
+	}
+	matched, _ := path.Match(normalizeRepo(pattern), repo)
+	return matched
+}
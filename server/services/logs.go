@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/romain325/doc-thor/server/models"
+)
+
+// LogStore keeps the full output of each build as a file in Dir, outside the
+// database.  The build record only holds the last TailBytes of it.
+type LogStore struct {
+	Dir string
+	// MaxBytes caps a stored log; the middle of a longer one is replaced by
+	// a truncation marker.  0 means unlimited.
+	MaxBytes int64
+	// TailBytes is how much of the end of the log is kept on the build.
+	TailBytes int
+}
+
+// LogChunk is a page of a stored log.  NextOffset equals Total once the end
+// has been reached.
+type LogChunk struct {
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Total      int64  `json:"total"`
+	Content    string `json:"content"`
+}
+
+func (s LogStore) path(buildID uint) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%d.log", buildID))
+}
+
+// Save stores logs for buildID, capped to MaxBytes, and returns the stored
+// size and the tail to keep on the build record.
+func (s LogStore) Save(buildID uint, logs string) (int64, string, error) {
+	logs = capLog(logs, s.MaxBytes)
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return 0, "", fmt.Errorf("create log dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".log-*")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(logs); err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), s.path(buildID)); err != nil {
+		return 0, "", err
+	}
+	return int64(len(logs)), logTail(logs, s.TailBytes), nil
+}
+
+// Read returns up to limit bytes of the full log of b from offset.  A chunk
+// never ends in the middle of a UTF-8 sequence.  Builds whose log was not
+// stored in Dir (finished before it existed, or the write failed) are served
+// from their Logs column.
+func (s LogStore) Read(b *models.Build, offset int64, limit int) (*LogChunk, error) {
+	f, err := os.Open(s.path(b.ID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return readChunk(strings.NewReader(b.Logs), int64(len(b.Logs)), offset, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return readChunk(f, info.Size(), offset, limit)
+}
+
+func readChunk(r io.ReaderAt, total, offset int64, limit int) (*LogChunk, error) {
+	offset = min(max(offset, 0), total)
+	buf := make([]byte, min(int64(limit), total-offset))
+	if _, err := r.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// A limit shorter than one rune still has to make progress.
+	if n := completeRunes(buf); offset+int64(len(buf)) < total && n > 0 {
+		buf = buf[:n]
+	}
+	return &LogChunk{
+		Offset:     offset,
+		NextOffset: offset + int64(len(buf)),
+		Total:      total,
+		Content:    string(buf),
+	}, nil
+}
+
+// Delete removes the stored logs of the given builds, ignoring missing ones.
+func (s LogStore) Delete(buildIDs ...uint) {
+	for _, id := range buildIDs {
+		os.Remove(s.path(id)) //nolint:errcheck
+	}
+}
+
+// capLog keeps the first and last max/2 bytes of logs: the head shows how the
+// build started, the tail why it ended.
+func capLog(logs string, max int64) string {
+	if max <= 0 || int64(len(logs)) <= max {
+		return logs
+	}
+	head := runeStart(logs, int(max/2))
+	tail := runeStart(logs, len(logs)-int(max/2))
+	return logs[:head] + fmt.Sprintf("\n[... %d bytes truncated ...]\n", tail-head) + logs[tail:]
+}
+
+// logTail returns the last n bytes of logs, starting at a line boundary when
+// there is one.
+func logTail(logs string, n int) string {
+	if n <= 0 || len(logs) <= n {
+		return logs
+	}
+	tail := logs[runeStart(logs, len(logs)-n):]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return tail
+}
+
+// runeStart moves i back to the start of the UTF-8 sequence it falls in.
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// completeRunes is the length of the longest prefix of b that does not end
+// with a partial UTF-8 sequence.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
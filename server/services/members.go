@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+var roleRanks = map[string]int{
+	models.RoleViewer:     1,
+	models.RoleMaintainer: 2,
+	models.RoleAdmin:      3,
+}
+
+// ValidateRole checks that role is one of the known roles.
+func ValidateRole(role string) error {
+	if roleRanks[role] == 0 {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of min.
+// Unknown roles, including the empty one, grant nothing.
+func RoleAtLeast(role, min string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[min]
+}
+
+// ProjectRole returns the role u holds on a project: the higher of their
+// global role and their membership of the project, if any.
+func ProjectRole(db *gorm.DB, u *models.User, projectID uint) (string, error) {
+	var m models.ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", projectID, u.ID).Limit(1).Find(&m).Error; err != nil {
+		return "", err
+	}
+	if m.ID != 0 && roleRanks[m.Role] > roleRanks[u.Role] {
+		return m.Role, nil
+	}
+	return u.Role, nil
+}
+
+// ListMembers returns a project's members ordered by username.
+func ListMembers(db *gorm.DB, projectID uint) ([]models.ProjectMember, error) {
+	var members []models.ProjectMember
+	err := db.Select("project_members.*, users.username").
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id = ?", projectID).
+		Order("users.username ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// GetMember returns username's membership of a project, or ErrNotFound.
+func GetMember(db *gorm.DB, projectID uint, username string) (*models.ProjectMember, error) {
+	var m models.ProjectMember
+	err := db.Select("project_members.*, users.username").
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id = ? AND users.username = ?", projectID, username).
+		Limit(1).Find(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 {
+		return nil, ErrNotFound
+	}
+	return &m, nil
+}
+
+// SetMember adds username to a project with role, or changes the role of an
+// existing member.  Returns ErrNotFound when the user does not exist.
+func SetMember(db *gorm.DB, projectID uint, username, role string) (*models.ProjectMember, error) {
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	var u models.User
+	if err := db.Where("username = ?", username).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var m models.ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", projectID, u.ID).Limit(1).Find(&m).Error; err != nil {
+		return nil, err
+	}
+	m.ProjectID, m.UserID, m.Role = projectID, u.ID, role
+	if err := db.Save(&m).Error; err != nil {
+		return nil, err
+	}
+	m.Username = u.Username
+	return &m, nil
+}
+
+// RemoveMember removes username from a project.  Returns ErrNotFound when
+// they are not a member.
+func RemoveMember(db *gorm.DB, projectID uint, username string) error {
+	res := db.Where("project_id = ? AND user_id = (SELECT id FROM users WHERE username = ?)", projectID, username).
+		Delete(&models.ProjectMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -12,8 +12,10 @@ import (
 
 // SyncNginxConfig rewrites the server-block file for a project based on
 // its currently-published versions.  If nothing is published the file is removed.
-// Storage path contract: <slug>/<version>/<file-path> (see CLAUDE.md).
-func SyncNginxConfig(db *gorm.DB, project *models.Project, nginxDir, storageEndpoint string) error {
+// Storage path contract: <slug>/<version>/<file-path> (see CLAUDE.md), on
+// whichever backend each version was uploaded to.  Docs that are not public
+// are guarded by an auth_request to the server at authUpstream.
+func SyncNginxConfig(db *gorm.DB, project *models.Project, nginxDir string, storage StorageLocations, authUpstream string) error {
 	versions, err := ListVersions(db, project.ID)
 	if err != nil {
 		return err
@@ -33,22 +35,76 @@ func SyncNginxConfig(db *gorm.DB, project *models.Project, nginxDir, storageEndp
 
 	var blocks []string
 	for _, v := range published {
+		location, err := renderLocation(project.Slug, v, storage)
+		if err != nil {
+			return err
+		}
+		guard := ""
+		if project.Visibility != "" && project.Visibility != models.VisibilityPublic {
+			guard = authUpstream
+		}
 		// versioned subdomain: <slug>-<tag>.docs.<domain>
-		blocks = append(blocks, renderBlock(project.Slug, v.Tag, v.Tag, storageEndpoint))
+		blocks = append(blocks, renderBlock(project.Slug, v.Tag, v.Tag, location, guard))
 		// bare subdomain served by latest
 		if v.IsLatest {
-			blocks = append(blocks, renderBlock(project.Slug, "", v.Tag, storageEndpoint))
+			blocks = append(blocks, renderBlock(project.Slug, "", v.Tag, location, guard))
 		}
 	}
 
 	return os.WriteFile(configPath, []byte(strings.Join(blocks, "\n\n")+"\n"), 0644)
 }
 
-func renderBlock(slug, versionSuffix, storageVersion, storageEndpoint string) string {
+// renderLocation returns the "location /" body serving a version's files:
+// proxied from the S3 endpoint, or read straight from the local directory.
+// The docs sign-in cookie is kept from the storage backend.
+func renderLocation(slug string, v models.Version, storage StorageLocations) (string, error) {
+	if v.Storage == StorageLocal {
+		if storage.LocalDir == "" {
+			return "", fmt.Errorf("version %s is on local storage but LOCAL_STORAGE_DIR is not set", v.Tag)
+		}
+		return fmt.Sprintf(`root %s;
+        index index.html;
+        try_files $uri $uri/ =404;`, filepath.Join(storage.LocalDir, slug, v.Tag)), nil
+	}
+	return fmt.Sprintf(`proxy_pass %s/%s/%s/;
+        proxy_set_header Host $host;
+        proxy_set_header Cookie "";`, strings.TrimRight(storage.Endpoint, "/"), slug, v.Tag), nil
+}
+
+// renderBlock renders one server block.  With authUpstream set, pages are
+// only served after an auth_request to the server's docs access check;
+// visitors who are not signed in get the login page in place of the page
+// they asked for.  /_doc-thor/ serves the login, SSO and logout pages on the
+// docs host itself, so the session cookie belongs to it.
+func renderBlock(slug, versionSuffix, storageVersion, location, authUpstream string) string {
 	subdomain := slug
 	if versionSuffix != "" {
 		subdomain = slug + "-" + versionSuffix
 	}
+	guard, authLocations := "", ""
+	if authUpstream != "" {
+		upstream := strings.TrimRight(authUpstream, "/") + "/api/v1/docs-auth"
+		guard = `auth_request /_doc-thor/check;
+        error_page 401 = /_doc-thor/login;
+        `
+		authLocations = fmt.Sprintf(`
+
+    location = /_doc-thor/check {
+        internal;
+        proxy_pass %s/check;
+        proxy_pass_request_body off;
+        proxy_set_header Content-Length "";
+        proxy_set_header X-Doc-Project "%s";
+    }
+
+    location /_doc-thor/ {
+        proxy_pass %s/;
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-Proto $scheme;
+        proxy_set_header X-Original-URI $request_uri;
+        proxy_set_header X-Real-IP $remote_addr;
+    }`, upstream, slug, upstream)
+	}
 	return fmt.Sprintf(`server {
     listen 80;
     server_name %s.docs.localhost;
@@ -57,8 +113,7 @@ func renderBlock(slug, versionSuffix, storageVersion, storageEndpoint string) st
     set $doc_version "%s";
 
     location / {
-        proxy_pass %s/%s/%s/;
-        proxy_set_header Host $host;
-    }
-}`, subdomain, slug, storageVersion, strings.TrimRight(storageEndpoint, "/"), slug, storageVersion)
+        %s%s
+    }%s
+}`, subdomain, slug, storageVersion, guard, location, authLocations)
 }
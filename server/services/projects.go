@@ -2,12 +2,32 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 
 	"github.com/romain325/doc-thor/server/models"
 	"gorm.io/gorm"
 )
 
-func CreateProject(db *gorm.DB, p *models.Project) error {
+func CreateProject(db *gorm.DB, policy ImagePolicy, p *models.Project) error {
+	if err := validateResources(p.Resources); err != nil {
+		return err
+	}
+	if p.BuildTimeout < 0 {
+		return ErrInvalidBuildTimeout
+	}
+	if err := ValidateLabels(p.RequiredLabels); err != nil {
+		return err
+	}
+	if p.Visibility == "" {
+		p.Visibility = models.VisibilityPublic
+	}
+	if err := ValidateVisibility(p.Visibility); err != nil {
+		return err
+	}
+	if err := policy.Check(p.DockerImage); err != nil {
+		return err
+	}
 	var count int64
 	db.Model(&models.Project{}).Where("slug = ?", p.Slug).Count(&count)
 	if count > 0 {
@@ -33,7 +53,21 @@ func GetProject(db *gorm.DB, slug string) (*models.Project, error) {
 	return &p, nil
 }
 
-func UpdateProject(db *gorm.DB, slug string, updates *models.Project) (*models.Project, error) {
+// ProjectUpdate carries the fields of a project to change; empty strings
+// and nil fields are left alone.
+type ProjectUpdate struct {
+	Name        string                 `json:"name"`
+	SourceURL   string                 `json:"source_url"`
+	DockerImage string                 `json:"docker_image"`
+	Resources   *models.BuildResources `json:"resources"`
+	// BuildTimeout 0 goes back to the builder default.
+	BuildTimeout *int `json:"build_timeout"`
+	// RequiredLabels replaces the list when non-nil; empty clears it.
+	RequiredLabels []string `json:"required_labels"`
+	Visibility     string   `json:"visibility"`
+}
+
+func UpdateProject(db *gorm.DB, policy ImagePolicy, slug string, updates *ProjectUpdate) (*models.Project, error) {
 	p, err := GetProject(db, slug)
 	if err != nil {
 		return nil, err
@@ -45,8 +79,35 @@ func UpdateProject(db *gorm.DB, slug string, updates *models.Project) (*models.P
 		p.SourceURL = updates.SourceURL
 	}
 	if updates.DockerImage != "" {
+		if err := policy.Check(updates.DockerImage); err != nil {
+			return nil, err
+		}
 		p.DockerImage = updates.DockerImage
 	}
+	if updates.Resources != nil {
+		if err := validateResources(updates.Resources); err != nil {
+			return nil, err
+		}
+		p.Resources = updates.Resources
+	}
+	if updates.BuildTimeout != nil {
+		if *updates.BuildTimeout < 0 {
+			return nil, ErrInvalidBuildTimeout
+		}
+		p.BuildTimeout = *updates.BuildTimeout
+	}
+	if updates.RequiredLabels != nil {
+		if err := ValidateLabels(updates.RequiredLabels); err != nil {
+			return nil, err
+		}
+		p.RequiredLabels = updates.RequiredLabels
+	}
+	if updates.Visibility != "" {
+		if err := ValidateVisibility(updates.Visibility); err != nil {
+			return nil, err
+		}
+		p.Visibility = updates.Visibility
+	}
 	// VCSConfig is updated via separate VCS integration endpoints
 	if err := db.Save(p).Error; err != nil {
 		return nil, err
@@ -54,12 +115,76 @@ func UpdateProject(db *gorm.DB, slug string, updates *models.Project) (*models.P
 	return p, nil
 }
 
-func DeleteProject(db *gorm.DB, slug string) error {
+func DeleteProject(db *gorm.DB, logs LogStore, slug string) error {
 	p, err := GetProject(db, slug)
 	if err != nil {
 		return err
 	}
+	var buildIDs []uint
+	db.Model(&models.Build{}).Where("project_id = ?", p.ID).Pluck("id", &buildIDs)
+	logs.Delete(buildIDs...)
+	db.Where("build_id IN (?)", db.Model(&models.Build{}).Select("id").Where("project_id = ?", p.ID)).Delete(&models.BuildStage{})
 	db.Where("project_id = ?", p.ID).Delete(&models.Build{})
+	db.Where("version_id IN (?)", db.Model(&models.Version{}).Select("id").Where("project_id = ?", p.ID)).Delete(&models.VersionFile{})
 	db.Where("project_id = ?", p.ID).Delete(&models.Version{})
+	db.Where("project_id = ?", p.ID).Delete(&models.ProjectVariable{})
+	db.Where("project_id = ?", p.ID).Delete(&models.BuildSchedule{})
+	db.Where("project_id = ?", p.ID).Delete(&models.ProjectMember{})
+	db.Where("project_id = ?", p.ID).Delete(&models.Token{})
 	return db.Delete(p).Error
 }
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateLabels checks builder labels, as required by a project or
+// advertised by a builder.
+func ValidateLabels(labels []string) error {
+	for _, l := range labels {
+		if !labelPattern.MatchString(l) {
+			return fmt.Errorf("%w: %q", ErrInvalidLabel, l)
+		}
+	}
+	return nil
+}
+
+func ValidateVisibility(v string) error {
+	switch v {
+	case models.VisibilityPublic, models.VisibilityInternal, models.VisibilityPrivate:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidVisibility, v)
+}
+
+// CanReadDocs reports whether user, nil when not signed in, may read a
+// project's published docs.  Private docs are for members and for global
+// maintainers and admins, who hold that role on every project (see
+// ProjectRole).
+func CanReadDocs(db *gorm.DB, user *models.User, p *models.Project) bool {
+	switch p.Visibility {
+	case "", models.VisibilityPublic:
+		return true
+	case models.VisibilityInternal:
+		return user != nil
+	}
+	if user == nil {
+		return false
+	}
+	if RoleAtLeast(user.Role, models.RoleMaintainer) {
+		return true
+	}
+	var n int64
+	db.Model(&models.ProjectMember{}).Where("project_id = ? AND user_id = ?", p.ID, user.ID).Count(&n)
+	return n > 0
+}
+
+// validateResources rejects nonsensical requests.  Upper bounds are not
+// checked here: they are builder configuration and enforced by each builder.
+func validateResources(r *models.BuildResources) error {
+	if r == nil {
+		return nil
+	}
+	if r.MemoryMB < 0 || r.CPUs < 0 || r.Pids < 0 {
+		return ErrInvalidResources
+	}
+	return nil
+}
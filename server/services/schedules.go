@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/romain325/doc-thor/server/cron"
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+// ScheduleUpdate carries the fields of a schedule to change; nil fields are
+// left alone.
+type ScheduleUpdate struct {
+	Cron    *string `json:"cron"`
+	Ref     *string `json:"ref"`
+	Tag     *string `json:"tag"`
+	Enabled *bool   `json:"enabled"`
+	CatchUp *bool   `json:"catch_up"`
+}
+
+// nextRun parses spec and returns its first activation after now.
+func nextRun(spec string, now time.Time) (time.Time, error) {
+	sched, err := cron.Parse(spec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidCron, err)
+	}
+	next := sched.Next(now.UTC())
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: %q never fires", ErrInvalidCron, spec)
+	}
+	return next, nil
+}
+
+func ListSchedules(db *gorm.DB, projectID uint) ([]models.BuildSchedule, error) {
+	var schedules []models.BuildSchedule
+	err := db.Where("project_id = ?", projectID).Order("id").Find(&schedules).Error
+	return schedules, err
+}
+
+// CreateSchedule validates s.Cron and stores s with its first run time.
+func CreateSchedule(db *gorm.DB, s *models.BuildSchedule) error {
+	next, err := nextRun(s.Cron, time.Now())
+	if err != nil {
+		return err
+	}
+	s.NextRunAt = next
+	return db.Create(s).Error
+}
+
+// GetSchedule returns one schedule of a project.
+func GetSchedule(db *gorm.DB, projectID, id uint) (*models.BuildSchedule, error) {
+	var s models.BuildSchedule
+	if err := db.Where("id = ? AND project_id = ?", id, projectID).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSchedule applies u.  The next run is recomputed from now when the
+// expression changes or the schedule is re-enabled, so neither causes an
+// immediate catch-up run.
+func UpdateSchedule(db *gorm.DB, projectID, id uint, u ScheduleUpdate) (*models.BuildSchedule, error) {
+	s, err := GetSchedule(db, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reschedule := false
+	if u.Cron != nil && *u.Cron != s.Cron {
+		s.Cron = *u.Cron
+		reschedule = true
+	}
+	if u.Enabled != nil {
+		reschedule = reschedule || (*u.Enabled && !s.Enabled)
+		s.Enabled = *u.Enabled
+	}
+	if u.Ref != nil {
+		s.Ref = *u.Ref
+	}
+	if u.Tag != nil {
+		s.Tag = *u.Tag
+	}
+	if u.CatchUp != nil {
+		s.CatchUp = *u.CatchUp
+	}
+	if reschedule {
+		next, err := nextRun(s.Cron, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		s.NextRunAt = next
+	}
+	if err := db.Save(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func DeleteSchedule(db *gorm.DB, projectID, id uint) error {
+	res := db.Where("id = ? AND project_id = ?", id, projectID).Delete(&models.BuildSchedule{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RunDueSchedules queues a build for every enabled schedule whose next run
+// is at or before now, and moves each one to its next activation after now.
+// A run later than grace is a missed run: it still builds when the schedule
+// has CatchUp, and is dropped otherwise.  A run is also dropped when the same
+// ref and tag already have a build waiting in the queue.  It returns the
+// number of builds queued.
+func RunDueSchedules(db *gorm.DB, now time.Time, grace time.Duration) (int, error) {
+	// Times are stored in UTC; SQLite compares them as text.
+	now = now.UTC()
+	var due []models.BuildSchedule
+	if err := db.Where("enabled = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, s := range due {
+		next, err := nextRun(s.Cron, now)
+		if err != nil {
+			// The expression was valid when stored; disable rather than
+			// retry it on every tick.
+			if uerr := db.Model(&s).Update("enabled", false).Error; uerr != nil {
+				log.Printf("schedule %d: %v; disabling it failed: %v", s.ID, err, uerr)
+			}
+			continue
+		}
+
+		missed := now.Sub(s.NextRunAt) > grace
+		if !missed || s.CatchUp {
+			var waiting int64
+			db.Model(&models.Build{}).
+				Where("project_id = ? AND ref = ? AND tag = ? AND status = ?", s.ProjectID, s.Ref, s.Tag, "pending").
+				Count(&waiting)
+			if waiting == 0 {
+				b, err := CreateBuild(db, s.ProjectID, s.Ref, s.Tag, "", TriggerSchedule)
+				if err != nil {
+					return queued, err
+				}
+				queued++
+				ran := now
+				s.LastRunAt = &ran
+				s.LastBuildID = &b.ID
+			}
+		}
+		s.NextRunAt = next
+		if err := db.Save(&s).Error; err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"github.com/romain325/doc-thor/server/secrets"
+	"gorm.io/gorm"
+)
+
+// MigrateSecrets brings every stored secret under the current master key.
+// VCS integration credentials still in plaintext are encrypted; encrypted
+// credentials and secret project variables sealed with an older key are
+// rewrapped.  It runs at startup and from the rotatekey command, and
+// returns how many values it changed.
+func MigrateSecrets(db *gorm.DB) (int, error) {
+	changed := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Raw rows: the model's serializer would hide which values are
+		// still plaintext.
+		var integrations []struct {
+			ID            uint
+			AccessToken   string
+			WebhookSecret string
+		}
+		if err := tx.Table("vcs_integrations").Select("id, access_token, webhook_secret").Find(&integrations).Error; err != nil {
+			return err
+		}
+		for _, row := range integrations {
+			updates := map[string]interface{}{}
+			for column, value := range map[string]string{"access_token": row.AccessToken, "webhook_secret": row.WebhookSecret} {
+				migrated, err := migrateSecret(value)
+				if err != nil {
+					return err
+				}
+				if migrated != value {
+					updates[column] = migrated
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Table("vcs_integrations").Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			changed += len(updates)
+		}
+
+		var variables []struct {
+			ID    uint
+			Value string
+		}
+		if err := tx.Table("project_variables").Select("id, value").Where("secret = ?", true).Find(&variables).Error; err != nil {
+			return err
+		}
+		for _, row := range variables {
+			rewrapped, err := secrets.Rewrap(row.Value)
+			if err != nil {
+				return err
+			}
+			if rewrapped == row.Value {
+				continue
+			}
+			if err := tx.Table("project_variables").Where("id = ?", row.ID).UpdateColumn("value", rewrapped).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// migrateSecret encrypts a plaintext credential or rewraps an encrypted one.
+func migrateSecret(value string) (string, error) {
+	switch {
+	case value == "":
+		return value, nil
+	case secrets.IsEncrypted(value):
+		return secrets.Rewrap(value)
+	default:
+		return secrets.Encrypt(value)
+	}
+}
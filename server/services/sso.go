@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+// SSORoleMapping turns the groups a user has at the identity provider into
+// a doc-thor role.  The highest matching role wins; a user in none of the
+// groups gets DefaultRole, and is refused when that is empty.  Leading
+// slashes are ignored, so Keycloak's "/docs-admins" matches "docs-admins".
+type SSORoleMapping struct {
+	AdminGroups      []string
+	MaintainerGroups []string
+	ViewerGroups     []string
+	DefaultRole      string
+}
+
+// Role returns the role for groups, or "" if the user may not sign in.
+func (m SSORoleMapping) Role(groups []string) string {
+	in := func(names []string) bool {
+		for _, g := range groups {
+			if slices.Contains(names, strings.TrimPrefix(g, "/")) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case in(m.AdminGroups):
+		return models.RoleAdmin
+	case in(m.MaintainerGroups):
+		return models.RoleMaintainer
+	case in(m.ViewerGroups):
+		return models.RoleViewer
+	}
+	return m.DefaultRole
+}
+
+// Configured reports whether any groups are mapped, making the identity
+// provider the source of truth for roles.
+func (m SSORoleMapping) Configured() bool {
+	return len(m.AdminGroups) > 0 || len(m.MaintainerGroups) > 0 || len(m.ViewerGroups) > 0
+}
+
+// SSOUser returns the account for a single sign-on identity, creating it
+// with role on first sign-in.  The username is only taken then, and must
+// not belong to an account already: a local account is never taken over by
+// an identity that happens to share its name (ErrAlreadyExists).
+//
+// With syncRole, a returning user's role is reset to role, so the identity
+// provider stays the source of truth; the last admin keeps their role
+// rather than be demoted.  Without it, roles are managed in doc-thor.
+func SSOUser(db *gorm.DB, subject, username, role string, syncRole bool) (*models.User, error) {
+	var u models.User
+	if err := db.Where("oidc_subject = ?", subject).Limit(1).Find(&u).Error; err != nil {
+		return nil, err
+	}
+	if u.ID == 0 {
+		if !usernamePattern.MatchString(username) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidUsername, username)
+		}
+		var n int64
+		if err := db.Model(&models.User{}).Where("username = ?", username).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, ErrAlreadyExists
+		}
+		u = models.User{Username: username, OIDCSubject: subject, Role: role}
+		return &u, db.Create(&u).Error
+	}
+
+	if !syncRole || u.Role == role {
+		return &u, nil
+	}
+	if u.Role == models.RoleAdmin {
+		err := checkNotLastAdmin(db, u.ID)
+		if errors.Is(err, ErrLastAdmin) {
+			log.Printf("sso: %s stays admin: %v", u.Username, err)
+			return &u, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	u.Role = role
+	return &u, db.Model(&u).Update("role", role).Error
+}
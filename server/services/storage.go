@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage backends a builder may report a version's output on.  They match
+// the builder's STORAGE_BACKEND values.
+const (
+	StorageS3    = "s3"
+	StorageLocal = "local"
+)
+
+// StorageLocations tells the server where each backend's objects can be read.
+type StorageLocations struct {
+	// Endpoint is the HTTP base URL objects of the S3 backend are served
+	// from: <Endpoint>/<key>.
+	Endpoint string
+	// LocalDir is the directory the local backend writes to.  nginx and the
+	// server must see it at this same path.
+	LocalDir string
+}
+
+// OpenObject returns a reader over the object stored under key on backend,
+// with its content type when known.
+func (l StorageLocations) OpenObject(ctx context.Context, backend, key string) (io.ReadCloser, string, error) {
+	if backend == StorageLocal {
+		if l.LocalDir == "" {
+			return nil, "", fmt.Errorf("object %s is on local storage but LOCAL_STORAGE_DIR is not set", key)
+		}
+		f, err := os.Open(filepath.Join(l.LocalDir, filepath.FromSlash(filepath.Clean("/"+key))))
+		if err != nil {
+			return nil, "", err
+		}
+		return f, "", nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(l.Endpoint, "/")+"/"+key, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("storage returned %d for %s", resp.StatusCode, key)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/romain325/doc-thor/server/models"
+	"gorm.io/gorm"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// MinPasswordLength is the shortest password accepted for an account.
+const MinPasswordLength = 8
+
+// ValidatePassword checks a new password before it is hashed.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// CreateUser creates an account with an already hashed password.  An empty
+// role gives the user RoleViewer.
+func CreateUser(db *gorm.DB, username, passwordHash, role string) (*models.User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidUsername, username)
+	}
+	if role == "" {
+		role = models.RoleViewer
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	var count int64
+	db.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return nil, ErrAlreadyExists
+	}
+	u := &models.User{Username: username, PasswordHash: passwordHash, Role: role}
+	if err := db.Create(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ListUsers returns every account, ordered by username.
+func ListUsers(db *gorm.DB) ([]models.User, error) {
+	var users []models.User
+	err := db.Order("username ASC").Find(&users).Error
+	return users, err
+}
+
+// GetUser looks an account up by username.
+func GetUser(db *gorm.DB, username string) (*models.User, error) {
+	var u models.User
+	if err := db.Where("username = ?", username).Limit(1).Find(&u).Error; err != nil {
+		return nil, err
+	}
+	if u.ID == 0 {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+// DeleteUser removes an account with its tokens and project memberships.
+// The last admin cannot be deleted.
+func DeleteUser(db *gorm.DB, username string) error {
+	u, err := GetUser(db, username)
+	if err != nil {
+		return err
+	}
+	if u.Role == models.RoleAdmin {
+		if err := checkNotLastAdmin(db, u.ID); err != nil {
+			return err
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", u.ID).Delete(&models.Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", u.ID).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(u).Error
+	})
+}
+
+// SetPassword replaces a user's password hash and ends their sessions and
+// docs sign-ins, except keepTokenID (0 for none) so a user changing their
+// own password stays signed in.  API keys are left alone.
+func SetPassword(db *gorm.DB, u *models.User, passwordHash string, keepTokenID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Update("password_hash", passwordHash).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND type <> ? AND id <> ?", u.ID, "apikey", keepTokenID).
+			Delete(&models.Token{}).Error
+	})
+}
+
+// SetUserRole changes a user's global role.  The last admin cannot be
+// demoted, so the instance always keeps someone able to manage it.
+func SetUserRole(db *gorm.DB, username, role string) (*models.User, error) {
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	u, err := GetUser(db, username)
+	if err != nil {
+		return nil, err
+	}
+	if u.Role == models.RoleAdmin && role != models.RoleAdmin {
+		if err := checkNotLastAdmin(db, u.ID); err != nil {
+			return nil, err
+		}
+	}
+	u.Role = role
+	if err := db.Model(u).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func checkNotLastAdmin(db *gorm.DB, userID uint) error {
+	var others int64
+	if err := db.Model(&models.User{}).Where("role = ? AND id <> ?", models.RoleAdmin, userID).Count(&others).Error; err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// BackfillRoles gives a role to accounts created before roles existed.
+// They all had full access, so superusers become admins and everyone else
+// a maintainer; if that leaves no admin, the oldest account becomes one.
+func BackfillRoles(db *gorm.DB) error {
+	unset := "role IS NULL OR role = ''"
+	if db.Migrator().HasColumn(&models.User{}, "is_superuser") {
+		if err := db.Model(&models.User{}).Where(unset).Where("is_superuser = ?", true).
+			Update("role", models.RoleAdmin).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Model(&models.User{}).Where(unset).Update("role", models.RoleMaintainer).Error; err != nil {
+		return err
+	}
+
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+	var oldest models.User
+	if err := db.Order("id ASC").Limit(1).Find(&oldest).Error; err != nil || oldest.ID == 0 {
+		return err
+	}
+	return db.Model(&oldest).Update("role", models.RoleAdmin).Error
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/romain325/doc-thor/server/models"
+	"github.com/romain325/doc-thor/server/secrets"
+	"gorm.io/gorm"
+)
+
+// secretMask replaces secret values wherever they appear in stored build output.
+const secretMask = "********"
+
+var variableKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedVariablePrefix is the namespace of the build metadata the builder
+// injects itself (DOCTHOR_PROJECT, DOCTHOR_VERSION, ...).
+const reservedVariablePrefix = "DOCTHOR_"
+
+// ValidateVariableKey checks that key is usable as a POSIX environment
+// variable name and does not shadow the injected build metadata.
+func ValidateVariableKey(key string) error {
+	if !variableKeyPattern.MatchString(key) {
+		return ErrInvalidVariableKey
+	}
+	if strings.HasPrefix(strings.ToUpper(key), reservedVariablePrefix) {
+		return ErrReservedVariableKey
+	}
+	return nil
+}
+
+// ListProjectVariables returns the variables of a project ordered by key.
+// Secret values are blanked; only their presence is reported.
+func ListProjectVariables(db *gorm.DB, projectID uint) ([]models.ProjectVariable, error) {
+	var vars []models.ProjectVariable
+	if err := db.Where("project_id = ?", projectID).Order("key ASC").Find(&vars).Error; err != nil {
+		return nil, err
+	}
+	for i := range vars {
+		if vars[i].Secret {
+			vars[i].Value = ""
+		}
+	}
+	return vars, nil
+}
+
+// GetProjectVariable returns one variable, its value blanked when secret.
+func GetProjectVariable(db *gorm.DB, projectID uint, key string) (*models.ProjectVariable, error) {
+	var v models.ProjectVariable
+	if err := db.Where("project_id = ? AND key = ?", projectID, key).Limit(1).Find(&v).Error; err != nil {
+		return nil, err
+	}
+	if v.ID == 0 {
+		return nil, ErrNotFound
+	}
+	if v.Secret {
+		v.Value = ""
+	}
+	return &v, nil
+}
+
+// SetProjectVariable creates or replaces a variable.  Secret values are
+// encrypted before they reach the database; the returned record has its
+// value blanked when secret.
+func SetProjectVariable(db *gorm.DB, projectID uint, key, value string, secret bool) (*models.ProjectVariable, error) {
+	if err := ValidateVariableKey(key); err != nil {
+		return nil, err
+	}
+
+	stored := value
+	if secret {
+		enc, err := secrets.Encrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		stored = enc
+	}
+
+	var v models.ProjectVariable
+	err := db.Where("project_id = ? AND key = ?", projectID, key).First(&v).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	v.ProjectID = projectID
+	v.Key = key
+	v.Value = stored
+	v.Secret = secret
+	if err := db.Save(&v).Error; err != nil {
+		return nil, err
+	}
+
+	if v.Secret {
+		v.Value = ""
+	}
+	return &v, nil
+}
+
+// DeleteProjectVariable removes a variable by key.
+func DeleteProjectVariable(db *gorm.DB, projectID uint, key string) error {
+	res := db.Where("project_id = ? AND key = ?", projectID, key).Delete(&models.ProjectVariable{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ResolveProjectEnv returns every variable of a project with secrets
+// decrypted, ready to be handed to a builder.
+func ResolveProjectEnv(db *gorm.DB, projectID uint) (map[string]string, error) {
+	var vars []models.ProjectVariable
+	if err := db.Where("project_id = ?", projectID).Find(&vars).Error; err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(vars))
+	for _, v := range vars {
+		value := v.Value
+		if v.Secret {
+			dec, err := secrets.Decrypt(v.Value)
+			if err != nil {
+				return nil, err
+			}
+			value = dec
+		}
+		env[v.Key] = value
+	}
+	return env, nil
+}
+
+// maskSecrets replaces every secret value of a project found in text with
+// secretMask.  If a secret cannot be decrypted it is skipped: masking is
+// best-effort and must not prevent a build result from being stored.
+func maskSecrets(db *gorm.DB, projectID uint, text string) string {
+	if text == "" {
+		return text
+	}
+	var vars []models.ProjectVariable
+	if err := db.Where("project_id = ? AND secret = ?", projectID, true).Find(&vars).Error; err != nil {
+		return text
+	}
+	for _, v := range vars {
+		value, err := secrets.Decrypt(v.Value)
+		if err != nil || value == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, value, secretMask)
+	}
+	return text
+}
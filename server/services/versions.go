@@ -8,26 +8,88 @@ import (
 )
 
 // CreateVersion registers a new published version for a project.  It does not
-// touch is_latest — promotion is an explicit step via UpdateVersion.
-func CreateVersion(db *gorm.DB, projectID, buildID uint, tag string) (*models.Version, error) {
+// touch is_latest — promotion is an explicit step via UpdateVersion.  A
+// rebuild of an existing tag (a scheduled rebuild, a branch mapped to a fixed
+// version) replaces that version's output in place and keeps its flags.
+func CreateVersion(db *gorm.DB, projectID, buildID uint, tag string, out BuildOutput) (*models.Version, error) {
+	if out.Storage == "" {
+		// Builders that predate pluggable storage only ever wrote to S3.
+		out.Storage = StorageS3
+	}
 	v := &models.Version{
 		ProjectID: projectID,
 		BuildID:   buildID,
 		Tag:       tag,
 		Published: true,
+		Storage:   out.Storage,
+		Archive:   out.Archive,
 	}
-	if err := db.Create(v).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Version
+		res := tx.Where("project_id = ? AND tag = ?", projectID, tag).Limit(1).Find(&existing)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			existing.BuildID = buildID
+			existing.Storage = out.Storage
+			existing.Archive = out.Archive
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("version_id = ?", existing.ID).Delete(&models.VersionFile{}).Error; err != nil {
+				return err
+			}
+			*v = existing
+		} else if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+		if len(out.Files) == 0 {
+			return nil
+		}
+		files := make([]models.VersionFile, len(out.Files))
+		for i, f := range out.Files {
+			files[i] = models.VersionFile{
+				VersionID:   v.ID,
+				Path:        f.Path,
+				Size:        f.Size,
+				SHA256:      f.SHA256,
+				ContentType: f.ContentType,
+			}
+		}
+		return tx.CreateInBatches(files, 500).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return v, nil
 }
 
+// ListVersionFiles returns the output manifest of a version, ordered by path.
+// It is empty for versions built before manifests were recorded.
+func ListVersionFiles(db *gorm.DB, versionID uint) ([]models.VersionFile, error) {
+	files := []models.VersionFile{}
+	err := db.Where("version_id = ?", versionID).Order("path").Find(&files).Error
+	return files, err
+}
+
 func ListVersions(db *gorm.DB, projectID uint) ([]models.Version, error) {
 	var versions []models.Version
 	err := db.Where("project_id = ?", projectID).Find(&versions).Error
 	return versions, err
 }
 
+func GetVersion(db *gorm.DB, projectID uint, tag string) (*models.Version, error) {
+	var v models.Version
+	if err := db.Where("tag = ? AND project_id = ?", tag, projectID).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
 func UpdateVersion(db *gorm.DB, projectID uint, tag string, updates map[string]any) (*models.Version, error) {
 	var v models.Version
 	if err := db.Where("tag = ? AND project_id = ?", tag, projectID).First(&v).Error; err != nil {
@@ -74,6 +74,9 @@ type DocThorConfig struct {
 	Name           string                 `yaml:"name" json:"name"`
 	DockerImage    string                 `yaml:"docker_image" json:"docker_image"`
 	BranchMappings []models.BranchMapping `yaml:"branch_mappings,omitempty" json:"branch_mappings,omitempty"`
+	BuildTimeout   int                    `yaml:"build_timeout,omitempty" json:"build_timeout,omitempty"` // seconds
+	RequiredLabels []string               `yaml:"required_labels,omitempty" json:"required_labels,omitempty"`
+	Visibility     string                 `yaml:"visibility,omitempty" json:"visibility,omitempty"`
 }
 
 // RepositoryInfo is metadata about a single repository.